@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/Dylan-Oleary/go-social/docs"
+	"github.com/Dylan-Oleary/go-social/internal/auth"
+	"github.com/Dylan-Oleary/go-social/internal/follow"
 	"github.com/Dylan-Oleary/go-social/internal/mailer"
 	"github.com/Dylan-Oleary/go-social/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -15,19 +17,49 @@ import (
 )
 
 type application struct {
-	config config
-	logger *zap.SugaredLogger
-	mailer mailer.Client
-	store  store.Storage
+	authenticator auth.Authenticator
+	config        config
+	follows       *follow.Service
+	logger        *zap.SugaredLogger
+	mailer        mailer.Client
+	store         store.Storage
 }
 
 type config struct {
 	addr        string
 	apiURL      string
+	auth        authConfig
+	comments    commentsConfig
 	frontendURL string
 	db          dbConfig
 	env         string
 	mail        mailConfig
+	pagination  paginationConfig
+	reactions   reactionsConfig
+	suggestions suggestionsConfig
+	sweep       sweepConfig
+	timeline    timelineConfig
+	trending    trendingConfig
+}
+
+type authConfig struct {
+	refresh refreshConfig
+	token   tokenConfig
+}
+
+type refreshConfig struct {
+	exp time.Duration
+}
+
+type tokenConfig struct {
+	aud    string
+	exp    time.Duration
+	iss    string
+	secret string
+}
+
+type commentsConfig struct {
+	maxDepth int
 }
 
 type dbConfig struct {
@@ -38,9 +70,38 @@ type dbConfig struct {
 }
 
 type mailConfig struct {
-	exp      time.Duration
-	mailTrap mailTrapConfig
-	sendGrid sendGridConfig
+	exp                   time.Duration
+	mailTrap              mailTrapConfig
+	passwordResetExp      time.Duration
+	passwordResetThrottle time.Duration
+	resendThrottle        time.Duration
+	sendGrid              sendGridConfig
+}
+
+type paginationConfig struct {
+	cursorSecret string
+}
+
+type reactionsConfig struct {
+	kinds []string
+}
+
+type suggestionsConfig struct {
+	batchSize int
+	interval  time.Duration
+}
+
+type sweepConfig struct {
+	gracePeriod time.Duration
+	interval    time.Duration
+}
+
+type timelineConfig struct {
+	fanoutLimit int
+}
+
+type trendingConfig struct {
+	interval time.Duration
 }
 
 type mailTrapConfig struct {
@@ -67,17 +128,42 @@ func (app *application) mount() http.Handler {
 		r.Get("/swagger/*", swagger.Handler(swagger.URL(fmt.Sprintf("%s/swagger/doc.json", app.config.addr))))
 
 		r.Route("/posts", func(r chi.Router) {
-			r.Post("/", app.createPostHandler)
+			r.Get("/explore", app.getExploreFeedHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Post("/", app.createPostHandler)
+
+				r.Route("/{postID}", func(r chi.Router) {
+					r.Use(app.postContextMiddleware)
+
+					r.Get("/", app.getPostHandler)
+					r.Delete("/", app.checkPostOwnership("moderator", app.deletePostHandler))
+					r.Patch("/", app.checkPostOwnership("admin", app.updatePostHandler))
 
-			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postContextMiddleware)
+					r.Route("/bookmark", func(r chi.Router) {
+						r.Put("/", app.addBookmarkHandler)
+						r.Delete("/", app.removeBookmarkHandler)
+					})
 
-				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.deletePostHandler)
-				r.Patch("/", app.updatePostHandler)
+					r.Route("/reactions/{kind}", func(r chi.Router) {
+						r.Put("/", app.addReactionHandler)
+						r.Delete("/", app.removeReactionHandler)
+					})
 
-				r.Route("/comments", func(r chi.Router) {
-					r.Post("/", app.addCommentsToPostHandler)
+					r.Route("/comments", func(r chi.Router) {
+						r.Get("/", app.getCommentsHandler)
+						r.Post("/", app.addCommentsToPostHandler)
+
+						r.Route("/{commentID}", func(r chi.Router) {
+							r.Use(app.commentContextMiddleware)
+
+							r.Get("/", app.getCommentHandler)
+							r.Delete("/", app.checkCommentOwnership("moderator", app.deleteCommentHandler))
+							r.Patch("/", app.checkCommentOwnership("admin", app.updateCommentHandler))
+						})
+					})
 				})
 			})
 		})
@@ -86,25 +172,80 @@ func (app *application) mount() http.Handler {
 			r.Put("/activate/{token}", app.activateUserHandler)
 
 			r.Route("/{userID}", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
 				r.Use(app.userContextMiddleware)
 
 				r.Get("/", app.getUserHandler)
+				r.Get("/followers", app.getFollowersHandler)
+				r.Get("/following", app.getFollowingHandler)
 				r.Route("/follow", func(r chi.Router) {
 					r.Put("/", app.followUserHandler)
+					r.Delete("/", app.unfollowUserHandler)
 				})
 				r.Route("/unfollow", func(r chi.Router) {
 					r.Put("/", app.unfollowUserHandler)
 				})
+				r.Route("/block", func(r chi.Router) {
+					r.Put("/", app.blockUserHandler)
+					r.Delete("/", app.unblockUserHandler)
+				})
+				r.Route("/mute", func(r chi.Router) {
+					r.Put("/", app.muteUserHandler)
+					r.Delete("/", app.unmuteUserHandler)
+				})
 			})
 
 			r.Group((func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
 				r.Get("/feed", app.getUserFeedHandler)
+				r.Get("/me/bookmarks", app.getBookmarksHandler)
+				r.Get("/me/mentions", app.getMentionsFeedHandler)
+				r.Patch("/me", app.updateUserHandler)
+				r.Get("/me/suggestions", app.getFollowSuggestionsHandler)
+
+				r.Route("/me/follow-requests", func(r chi.Router) {
+					r.Get("/", app.getFollowRequestsHandler)
+					r.Put("/{requestID}/approve", app.approveFollowRequestHandler)
+					r.Put("/{requestID}/reject", app.rejectFollowRequestHandler)
+				})
 			}))
 		})
 
+		r.Route("/search", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+
+			r.Get("/", app.searchHandler)
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/trending", app.getTrendingTagsHandler)
+
+			r.Route("/{tag}/follow", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+
+				r.Put("/", app.followTagHandler)
+				r.Delete("/", app.unfollowTagHandler)
+			})
+		})
+
 		r.Route("/authentication", func(r chi.Router) {
 			r.Route("/user", func(r chi.Router) {
 				r.Post("/", app.registerUserHandler)
+				r.Post("/invitation", app.resendInvitationHandler)
+			})
+			r.Route("/token", func(r chi.Router) {
+				r.Post("/", app.createTokenHandler)
+			})
+			r.Route("/refresh", func(r chi.Router) {
+				r.Post("/", app.refreshTokenHandler)
+			})
+			r.Route("/logout", func(r chi.Router) {
+				r.Post("/", app.logoutHandler)
+			})
+			r.Route("/password-reset", func(r chi.Router) {
+				r.Post("/", app.requestPasswordResetHandler)
+				r.Put("/{token}", app.resetPasswordHandler)
 			})
 		})
 
@@ -113,6 +254,18 @@ func (app *application) mount() http.Handler {
 	return r
 }
 
+func (app *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", err)
+			}
+		}()
+
+		fn()
+	}()
+}
+
 func (app *application) run(mux http.Handler) error {
 	// Docs
 	docs.SwaggerInfo.Version = version
@@ -127,6 +280,8 @@ func (app *application) run(mux http.Handler) error {
 		WriteTimeout: time.Second * 30,
 	}
 
+	app.startJobs()
+
 	app.logger.Infow("Server has started", "addr", app.config.addr, "env", app.config.env)
 
 	return srv.ListenAndServe()
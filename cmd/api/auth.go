@@ -3,14 +3,25 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Dylan-Oleary/go-social/internal/mailer"
 	"github.com/Dylan-Oleary/go-social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+var (
+	errInactiveUser        = errors.New("user has not been activated")
+	errInvalidCredentials  = errors.New("invalid email or password")
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
@@ -104,3 +115,394 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		app.internalServerError(w, r, err)
 	}
 }
+
+type ResendInvitationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// resendInvitationHandler godoc
+//
+//	@Summary		Resends an activation email
+//	@Description	Issues a new activation token and resends the welcome email for a pending account. The response is the same whether or not the email belongs to a pending account, and resends within a few minutes of the last invitation are dropped.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResendInvitationPayload	true	"User email"
+//	@Success		202		{string}	string					"Invitation resent"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/user/invitation [post]
+func (app *application) resendInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendInvitationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user == nil || user.IsActive {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err = app.store.Users.Reinvite(ctx, user.ID, hashToken, app.config.mail.exp, app.config.mail.resendThrottle)
+	if err != nil {
+		switch err {
+		case store.ErrThrottled:
+			// Answered like any other email, so as not to reveal it is pending
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	isProdEnv := app.config.env == "production"
+	mailVars := struct {
+		ActivationURL string
+		Username      string
+	}{
+		ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken),
+		Username:      user.Username,
+	}
+
+	app.background(func() {
+		if _, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, mailVars, !isProdEnv); err != nil {
+			app.logger.Errorw("error resending welcome email", "error", err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type CreateUserTokenPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+type UserToken struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// createTokenHandler godoc
+//
+//	@Summary		Creates a token
+//	@Description	Exchanges user credentials for an access token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
+//	@Success		201		{object}	UserToken				"Token"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/token [post]
+func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateUserTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedError(w, errInvalidCredentials)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := user.Password.Compare(payload.Password); err != nil {
+		app.unauthorizedError(w, errInvalidCredentials)
+		return
+	}
+
+	if !user.IsActive {
+		app.forbiddenError(w, errInactiveUser)
+		return
+	}
+
+	token, err := app.generateAccessToken(user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	refreshToken := uuid.New().String()
+	if err := app.store.RefreshTokens.Create(r.Context(), user.ID, refreshToken, app.config.auth.refresh.exp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	userToken := &UserToken{
+		Token:        token,
+		RefreshToken: refreshToken,
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, userToken); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access token and a rotated refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	UserToken			"Token"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	refreshToken := uuid.New().String()
+
+	rotated, err := app.store.RefreshTokens.Rotate(ctx, payload.RefreshToken, refreshToken, app.config.auth.refresh.exp)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedError(w, errInvalidRefreshToken)
+		case store.ErrTokenReused:
+			app.logger.Warnw("refresh token reuse detected", "ip", r.RemoteAddr)
+			app.unauthorizedError(w, errInvalidRefreshToken)
+		case store.ErrUserInactive:
+			app.unauthorizedError(w, errInactiveUser)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	token, err := app.generateAccessToken(rotated.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	userToken := &UserToken{
+		Token:        token,
+		RefreshToken: refreshToken,
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, userToken); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out a user
+//	@Description	Revokes a refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		204		{string}	string				"Token revoked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := app.store.RefreshTokens.Revoke(r.Context(), payload.RefreshToken); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedError(w, errInvalidRefreshToken)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) generateAccessToken(userId int64) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{app.config.auth.token.aud},
+		ExpiresAt: jwt.NewNumericDate(now.Add(app.config.auth.token.exp)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    app.config.auth.token.iss,
+		NotBefore: jwt.NewNumericDate(now),
+		Subject:   strconv.FormatInt(userId, 10),
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+type RequestPasswordResetPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// requestPasswordResetHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a password reset link if an active account exists for the email, replacing any earlier link. Requests within a few minutes of the last link are dropped
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RequestPasswordResetPayload	true	"User email"
+//	@Success		202		{string}	string						"Password reset requested"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password-reset [post]
+func (app *application) requestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RequestPasswordResetPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// The response is identical whether or not the email exists, so the
+	// endpoint can't be used to discover accounts.
+	if user != nil && user.IsActive {
+		plainToken := uuid.New().String()
+		err := app.store.Users.CreatePasswordReset(ctx, user.ID, plainToken, app.config.mail.passwordResetExp, app.config.mail.passwordResetThrottle)
+		if err != nil {
+			switch err {
+			case store.ErrThrottled:
+				w.WriteHeader(http.StatusAccepted)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		isProdEnv := app.config.env == "production"
+		mailVars := struct {
+			ExpiresIn int
+			ResetURL  string
+			Username  string
+		}{
+			ExpiresIn: int(app.config.mail.passwordResetExp.Minutes()),
+			ResetURL:  fmt.Sprintf("%s/password-reset/%s", app.config.frontendURL, plainToken),
+			Username:  user.Username,
+		}
+
+		app.background(func() {
+			if _, err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, mailVars, !isProdEnv); err != nil {
+				app.logger.Errorw("error sending password reset email", "error", err)
+			}
+		})
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type ResetPasswordPayload struct {
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using a password reset token and signs the user out everywhere
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	path		string					true	"Password Reset Token"
+//	@Param			payload	body		ResetPasswordPayload	true	"New password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password-reset/{token} [put]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user := &store.User{}
+	if err := user.Password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.ResetPassword(r.Context(), token, user); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
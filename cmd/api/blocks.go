@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	errSelfBlock = errors.New("you cannot block yourself")
+	errSelfMute  = errors.New("you cannot mute yourself")
+)
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Hides the user and the authenticated user from each other and removes any follows between them. Blocking again has no effect
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyToUser(w, r, errSelfBlock, app.store.Blocks.Block)
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Lifts a block on a user. Follows removed by the block are not restored
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyToUser(w, r, errSelfBlock, app.store.Blocks.Unblock)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Leaves the user's posts and mentions out of the authenticated user's feeds. Muting again has no effect
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyToUser(w, r, errSelfMute, app.store.Mutes.Mute)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmutes a user
+//	@Description	Puts the user's posts and mentions back in the authenticated user's feeds
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [delete]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.applyToUser(w, r, errSelfMute, app.store.Mutes.Unmute)
+}
+
+// applyToUser calls apply with the authenticated user and the user in the
+// URL, responding with errSelf when they are the same.
+func (app *application) applyToUser(w http.ResponseWriter, r *http.Request, errSelf error, apply func(ctx context.Context, userId int64, otherId int64) error) {
+	user := getAuthUserFromCtx(r)
+	other := getUserFromCtx(r)
+
+	if user.ID == other.ID {
+		app.badRequestError(w, errSelf)
+		return
+	}
+
+	if err := apply(r.Context(), user.ID, other.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
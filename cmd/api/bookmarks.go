@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dylan-Oleary/go-social/internal/store"
+)
+
+// AddBookmark godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post to the authenticated user's bookmarks. Bookmarking it again has no effect
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Post bookmarked"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) addBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.Bookmarks.Add(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveBookmark godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the authenticated user's bookmarks
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Bookmark removed"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) removeBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.Bookmarks.Remove(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		Fetches the user's bookmarks
+//	@Description	Fetches the authenticated user's bookmarked posts, most recently bookmarked first
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	[]store.Bookmark
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	bq := store.PaginationCursorQuery{
+		Limit: 20,
+	}
+
+	bq, err := bq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(bq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	bookmarks, cursors, err := app.store.Bookmarks.List(r.Context(), user.ID, bq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, bookmarks, cursors); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -1,15 +1,104 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/Dylan-Oleary/go-social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtxKey commentKey = "comment"
+
+var (
+	errCommentDeleted  = errors.New("comment has been deleted")
+	errInvalidParent   = errors.New("parent comment does not belong to this post")
+	errMaxDepthReached = errors.New("maximum reply depth reached")
 )
 
 type CreateCommentPayload struct {
-	Content string `json:"content" validate:"required,max=1000"`
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gt=0"`
 }
 
+// GetComments godoc
+//
+//	@Summary		Fetches the comments on a post
+//	@Description	Fetches a page of top-level comments for a post along with their replies
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			depth	query		int		false	"Maximum reply depth"
+//	@Success		200		{object}	[]store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	cq := store.PaginationCommentsQuery{
+		Limit: 20,
+		Sort:  "desc",
+		Depth: app.config.comments.maxDepth,
+	}
+
+	cq, err := cq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	cq.Depth = min(cq.Depth, app.config.comments.maxDepth)
+
+	post := getPostFromCtx(r)
+
+	comments, nextCursor, err := app.store.Comments.List(r.Context(), post.ID, getAuthUserFromCtx(r).ID, cq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, comments, store.PageCursors{Next: nextCursor}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// AddComment godoc
+//
+//	@Summary		Comments on a post
+//	@Description	Adds a comment to a post as the authenticated user
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
 func (app *application) addCommentsToPostHandler(w http.ResponseWriter, r *http.Request) {
 	var payload CreateCommentPayload
 	if err := readJSON(w, r, &payload); err != nil {
@@ -22,18 +111,213 @@ func (app *application) addCommentsToPostHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	ctx := r.Context()
 	post := getPostFromCtx(r)
+	user := getAuthUserFromCtx(r)
 	comment := store.Comment{
-		Content: payload.Content,
-		PostID:  post.ID,
-		// TODO: Change after auth
-		UserID: 1,
+		Content:  payload.Content,
+		PostID:   post.ID,
+		UserID:   user.ID,
+		ParentID: payload.ParentID,
 	}
 
-	if err := app.store.Comments.Create(r.Context(), &comment); err != nil {
-		app.internalServerError(w, r, err)
+	if payload.ParentID != nil {
+		parent, err := app.store.Comments.GetByID(ctx, *payload.ParentID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestError(w, errInvalidParent)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if parent.PostID != post.ID {
+			app.badRequestError(w, errInvalidParent)
+			return
+		}
+
+		if parent.IsDeleted {
+			app.badRequestError(w, errCommentDeleted)
+			return
+		}
+
+		depth, err := app.store.Comments.GetDepth(ctx, parent.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if depth+1 > app.config.comments.maxDepth {
+			app.badRequestError(w, errMaxDepthReached)
+			return
+		}
+
+		comment.Depth = depth + 1
+	}
+
+	if err := app.store.Comments.Create(ctx, &comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrBlocked):
+			app.forbiddenError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
 	app.jsonResponse(w, http.StatusCreated, comment)
 }
+
+// GetComment godoc
+//
+//	@Summary		Fetches a comment
+//	@Description	Fetches a comment on a post by ID
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		200			{object}	store.Comment
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [get]
+func (app *application) getCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type UpdateCommentPayload struct {
+	Content *string `json:"content" validate:"omitempty,max=1000"`
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Updates a comment
+//	@Description	Updates a comment on a post by ID
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+	if comment.IsDeleted {
+		app.notFoundError(w, errCommentDeleted)
+		return
+	}
+
+	var payload UpdateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if payload.Content != nil {
+		comment.Content = *payload.Content
+	}
+
+	if err := app.store.Comments.Update(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			app.conflictError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment on a post by ID. Comments with replies are tombstoned instead.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{object}	string
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	err := app.store.Comments.DeleteByID(r.Context(), comment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) commentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentId, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestError(w, err)
+			return
+		}
+
+		ctx := r.Context()
+		comment, err := app.store.Comments.GetByID(ctx, commentId, getAuthUserFromCtx(r).ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		// A comment is only reachable through the post it belongs to
+		post := getPostFromCtx(r)
+		if comment.PostID != post.ID {
+			app.notFoundError(w, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtxKey, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	return r.Context().Value(commentCtxKey).(*store.Comment)
+}
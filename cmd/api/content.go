@@ -0,0 +1,66 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTagLength = 32
+	maxTags      = 10
+	maxMentions  = 20
+)
+
+var (
+	hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#&])#([\p{L}\p{N}_]+)`)
+	mentionRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_]+)`)
+)
+
+// parseHashtags returns the #hashtags in content, without the #.
+func parseHashtags(content string) []string {
+	return submatches(hashtagRegex, content)
+}
+
+// parseMentions returns the usernames @mentioned in content, each once.
+func parseMentions(content string) []string {
+	mentions := submatches(mentionRegex, content)
+	slices.Sort(mentions)
+	mentions = slices.Compact(mentions)
+
+	if len(mentions) > maxMentions {
+		mentions = mentions[:maxMentions]
+	}
+
+	return mentions
+}
+
+// normalizeTags lower-cases tags and drops a leading #, then drops empty,
+// overlong and repeated tags, keeping the first maxTags of what is left.
+func normalizeTags(tags ...[]string) []string {
+	normalized := []string{}
+	for _, tag := range slices.Concat(tags...) {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || slices.Contains(normalized, tag) {
+			continue
+		}
+
+		normalized = append(normalized, tag)
+		if len(normalized) == maxTags {
+			break
+		}
+	}
+
+	return normalized
+}
+
+func submatches(re *regexp.Regexp, s string) []string {
+	matches := []string{}
+	for _, match := range re.FindAllStringSubmatch(s, -1) {
+		matches = append(matches, match[1])
+	}
+
+	return matches
+}
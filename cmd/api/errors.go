@@ -12,6 +12,10 @@ func (app *application) conflictError(w http.ResponseWriter, err error) {
 	writeJSONError(w, http.StatusConflict, err.Error())
 }
 
+func (app *application) forbiddenError(w http.ResponseWriter, err error) {
+	writeJSONError(w, http.StatusForbidden, err.Error())
+}
+
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Errorw("Internal server Error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 
@@ -21,3 +25,13 @@ func (app *application) internalServerError(w http.ResponseWriter, r *http.Reque
 func (app *application) notFoundError(w http.ResponseWriter, err error) {
 	writeJSONError(w, http.StatusNotFound, err.Error())
 }
+
+func (app *application) unprocessableEntityError(w http.ResponseWriter, err error) {
+	writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+}
+
+func (app *application) unauthorizedError(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer charset="UTF-8"`)
+
+	writeJSONError(w, http.StatusUnauthorized, err.Error())
+}
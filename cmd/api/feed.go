@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/Dylan-Oleary/go-social/internal/store"
@@ -9,7 +10,7 @@ import (
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches posts from the user and the users they follow
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
@@ -17,11 +18,13 @@ import (
 //	@Param			until	query		string	false	"Until"
 //	@Param			limit	query		int		false	"Limit"
 //	@Param			offset	query		int		false	"Offset"
-//	@Param			sort	query		string	false	"Sort"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			sort	query		string	false	"Sort: asc, desc, top (last 7 days) or hot (last 2 days)"
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
 //	@Success		200		{object}	[]store.PostWithMetadata
 //	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
@@ -45,16 +48,132 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	ctx := r.Context()
+	user := getAuthUserFromCtx(r)
 
-	// TODO: Auth User Id From Token
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(43), fq)
+	feed, cursors, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
 	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, 200, feed, cursors); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+}
+
+// getExploreFeedHandler godoc
+//
+//	@Summary		Fetches the explore feed
+//	@Description	Fetches the newest posts from every user
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			sort	query		string	false	"Sort: asc, desc, top (last 7 days) or hot (last 2 days)"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/posts/explore [get]
+func (app *application) getExploreFeedHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginationFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	feed, cursors, err := app.store.Posts.GetExploreFeed(r.Context(), 0, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
 
-	if err := app.jsonResponse(w, 200, feed); err != nil {
+	if err := app.paginatedJSONResponse(w, http.StatusOK, feed, cursors); err != nil {
 		app.internalServerError(w, r, err)
+	}
+}
+
+// getMentionsFeedHandler godoc
+//
+//	@Summary		Fetches posts mentioning the user
+//	@Description	Fetches posts that @mention the authenticated user
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Param			sort	query		string	false	"Sort: asc, desc, top (last 7 days) or hot (last 2 days)"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mentions [get]
+func (app *application) getMentionsFeedHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginationFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+		Tags:   []string{},
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
 		return
 	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	feed, cursors, err := app.store.Posts.GetMentionsFeed(r.Context(), user.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, feed, cursors); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dylan-Oleary/go-social/internal/follow"
+	"github.com/Dylan-Oleary/go-social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetFollowRequests godoc
+//
+//	@Summary		Fetches the authenticated user's follow requests
+//	@Description	Fetches the pending requests to follow the authenticated user, most recent first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	[]store.FollowRequest
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginationCursorQuery{
+		Limit: 20,
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	requests, cursors, err := app.store.Followers.ListRequests(r.Context(), user.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, requests, cursors); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approves a follow request
+//	@Description	Makes the requester a follower of the authenticated user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			requestID	path		int		true	"Follow request ID"
+//	@Success		204			{string}	string	"Follow request approved"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{requestID}/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.follows.ApproveRequest)
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Drops a pending request to follow the authenticated user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			requestID	path		int		true	"Follow request ID"
+//	@Success		204			{string}	string	"Follow request rejected"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{requestID}/reject [put]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.follows.RejectRequest)
+}
+
+// resolveFollowRequest applies resolve to the authenticated user's follow
+// request in the URL.
+func (app *application) resolveFollowRequest(w http.ResponseWriter, r *http.Request, resolve func(ctx context.Context, userId int64, requestId int64) error) {
+	requestId, err := strconv.ParseInt(chi.URLParam(r, "requestID"), 10, 64)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	if err := resolve(r.Context(), user.ID, requestId); err != nil {
+		switch {
+		case errors.Is(err, follow.ErrRequestNotFound):
+			app.notFoundError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
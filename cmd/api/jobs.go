@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func (app *application) startJobs() {
+	app.runJob("sweep inactive users", app.config.sweep.interval, app.sweepInactiveUsers)
+	app.runJob("refresh trending tags", app.config.trending.interval, app.refreshTrendingTags)
+	app.runJob("refresh follow suggestions", app.config.suggestions.interval, app.refreshFollowSuggestions)
+}
+
+// runJob runs job immediately and then once every interval until the process
+// exits. A failing or panicking run is logged and does not stop the schedule.
+func (app *application) runJob(name string, interval time.Duration, job func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			app.runJobOnce(name, interval, job)
+			<-ticker.C
+		}
+	}()
+}
+
+func (app *application) runJobOnce(name string, timeout time.Duration, job func(ctx context.Context) error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Errorw("job panicked", "job", name, "error", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := job(ctx); err != nil {
+		app.logger.Errorw("job failed", "job", name, "error", err.Error())
+	}
+}
+
+func (app *application) sweepInactiveUsers(ctx context.Context) error {
+	deleted, err := app.store.Users.DeleteInactive(ctx, time.Now().Add(-app.config.sweep.gracePeriod))
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("swept inactive users", "count", deleted)
+	}
+
+	return nil
+}
+
+func (app *application) refreshTrendingTags(ctx context.Context) error {
+	return app.store.Tags.RefreshTrending(ctx)
+}
+
+func (app *application) refreshFollowSuggestions(ctx context.Context) error {
+	refreshed, err := app.store.Suggestions.Refresh(ctx, app.config.suggestions.batchSize)
+	if err != nil {
+		return err
+	}
+
+	if refreshed > 0 {
+		app.logger.Infow("refreshed follow suggestions", "users", refreshed)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/Dylan-Oleary/go-social/internal/store"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -46,3 +47,12 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 
 	return writeJSON(w, status, &envelope{Data: data})
 }
+
+func (app *application) paginatedJSONResponse(w http.ResponseWriter, status int, data any, cursors store.PageCursors) error {
+	type envelope struct {
+		Data any `json:"data"`
+		store.PageCursors
+	}
+
+	return writeJSON(w, status, &envelope{Data: data, PageCursors: cursors})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dylan-Oleary/go-social/internal/store"
+)
+
+type authUserKey string
+
+const authUserCtxKey authUserKey = "authUser"
+
+var errForbidden = errors.New("you do not have permission to perform this action")
+
+func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			app.unauthorizedError(w, errors.New("authorization header is missing"))
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			app.unauthorizedError(w, errors.New("authorization header is malformed"))
+			return
+		}
+
+		jwtToken, err := app.authenticator.ValidateToken(parts[1])
+		if err != nil {
+			app.unauthorizedError(w, err)
+			return
+		}
+
+		subject, err := jwtToken.Claims.GetSubject()
+		if err != nil {
+			app.unauthorizedError(w, err)
+			return
+		}
+
+		userId, err := strconv.ParseInt(subject, 10, 64)
+		if err != nil {
+			app.unauthorizedError(w, err)
+			return
+		}
+
+		ctx := r.Context()
+		user, err := app.store.Users.GetByID(ctx, userId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.unauthorizedError(w, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if !user.IsActive {
+			app.unauthorizedError(w, errInactiveUser)
+			return
+		}
+
+		ctx = context.WithValue(ctx, authUserCtxKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// checkPostOwnership lets the author of a post through, along with any user
+// whose role is at least requiredRole.
+func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := getAuthUserFromCtx(r)
+		post := getPostFromCtx(r)
+
+		if post.UserID == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenError(w, errForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// checkCommentOwnership applies the same rules as checkPostOwnership to the
+// comment in the request context.
+func (app *application) checkCommentOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := getAuthUserFromCtx(r)
+		comment := getCommentFromCtx(r)
+
+		if comment.UserID == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenError(w, errForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
+	role, err := app.store.Roles.GetByName(ctx, roleName)
+	if err != nil {
+		return false, err
+	}
+
+	return user.Role.Level >= role.Level, nil
+}
+
+func getAuthUserFromCtx(r *http.Request) *store.User {
+	return r.Context().Value(authUserCtxKey).(*store.User)
+}
@@ -14,10 +14,17 @@ type postKey string
 
 const postCtxKey postKey = "post"
 
+var errRepostNotEditable = errors.New("reposts cannot be edited")
+
+// CreatePostPayload creates a post, a quote of another post when QuoteOfID is
+// set, or a plain repost of one when RepostOfID is set, which takes nothing
+// else.
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title      string   `json:"title" validate:"required_without=RepostOfID,excluded_with=RepostOfID,max=100"`
+	Content    string   `json:"content" validate:"required_without=RepostOfID,excluded_with=RepostOfID,max=1000"`
+	Tags       []string `json:"tags" validate:"excluded_with=RepostOfID,max=10"`
+	RepostOfID *int64   `json:"repost_of_id" validate:"omitempty,excluded_with=QuoteOfID"`
+	QuoteOfID  *int64   `json:"quote_of_id"`
 }
 
 // GetPost godoc
@@ -27,22 +34,57 @@ type CreatePostPayload struct {
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
-//	@Success		200	{object}	store.Post
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id				path		int	true	"Post ID"
+//	@Param			comments_limit	query		int	false	"Number of top-level comments to include"
+//	@Success		200				{object}	store.Post
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
+	pq := store.PostQuery{
+		CommentsLimit: 5,
+	}
+
+	pq, err := pq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
 	post := getPostFromCtx(r)
+	post.Comments = []store.Comment{}
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	myReactions, err := app.store.Reactions.GetUserKinds(r.Context(), post.ID, getAuthUserFromCtx(r).ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	post.Comments = comments
+	post.MyReactions = myReactions
+
+	if pq.CommentsLimit > 0 {
+		cq := store.PaginationCommentsQuery{
+			Limit: pq.CommentsLimit,
+			Sort:  "desc",
+			Depth: 0,
+		}
+
+		comments, nextCursor, err := app.store.Comments.List(r.Context(), post.ID, getAuthUserFromCtx(r).ID, cq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		post.Comments = comments
+		post.CommentsNextCursor = nextCursor
+	}
 
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -52,7 +94,7 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // CreatePost godoc
 //
 //	@Summary		Creates a post
-//	@Description	Creates a post
+//	@Description	Creates a post, a quote of another post or a repost of one
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -60,6 +102,8 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 //	@Success		201		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		404		{object}	error	"Reposted or quoted post not found"
+//	@Failure		409		{object}	error	"Post already reposted"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts [post]
@@ -75,19 +119,37 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	user := getAuthUserFromCtx(r)
+	explicitTags := normalizeTags(payload.Tags)
 	post := store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		// TODO: Change after auth
-		UserID: 1,
+		Title:        payload.Title,
+		Content:      payload.Content,
+		Tags:         normalizeTags(explicitTags, parseHashtags(payload.Content)),
+		ExplicitTags: explicitTags,
+		Mentions:     parseMentions(payload.Content),
+		UserID:       user.ID,
+		RepostOfID:   payload.RepostOfID,
+		QuoteOfID:    payload.QuoteOfID,
 	}
 
 	if err := app.store.Posts.Create(r.Context(), &post); err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	app.background(func() {
+		if err := app.store.Timelines.FanOut(context.Background(), post.ID, app.config.timeline.fanoutLimit); err != nil {
+			app.logger.Errorw("error fanning out post", "post_id", post.ID, "error", err)
+		}
+	})
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -103,6 +165,8 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 //	@Produce		json
 //	@Param			id	path		int	true	"Post ID"
 //	@Success		204	{object} string
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
@@ -132,7 +196,7 @@ type UpdatePostPayload struct {
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID. Plain reposts carry no title or content of their own and cannot be edited
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -141,12 +205,18 @@ type UpdatePostPayload struct {
 //	@Success		200		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		404		{object}	error
+//	@Failure		422		{object}	error	"Post is a repost"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	if post.RepostOfID != nil {
+		app.unprocessableEntityError(w, errRepostNotEditable)
+		return
+	}
 
 	var payload UpdatePostPayload
 	if err := readJSON(w, r, &payload); err != nil {
@@ -166,6 +236,10 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Title = *payload.Title
 	}
 
+	// Hashtags are parsed from the current content only, so removed ones go
+	post.Tags = normalizeTags(post.ExplicitTags, parseHashtags(post.Content))
+	post.Mentions = parseMentions(post.Content)
+
 	if err := app.store.Posts.Update(r.Context(), post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -196,11 +270,44 @@ func (app *application) postContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Posts of private users are not found by anyone but their followers
+		visible, err := app.canViewPost(ctx, post, getAuthUserFromCtx(r).ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			app.notFoundError(w, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtxKey, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// canViewPost reports whether the viewer may see the post and, for a repost,
+// the post it reposts.
+func (app *application) canViewPost(ctx context.Context, post *store.Post, viewerId int64) (bool, error) {
+	userIds := []int64{post.UserID}
+	if post.RepostOfID != nil {
+		original, err := app.store.Posts.GetByID(ctx, *post.RepostOfID)
+		if err != nil {
+			return false, err
+		}
+		userIds = append(userIds, original.UserID)
+	}
+
+	for _, userId := range userIds {
+		visible, err := app.store.Users.IsVisibleTo(ctx, userId, viewerId)
+		if err != nil || !visible {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	return r.Context().Value(postCtxKey).(*store.Post)
 }
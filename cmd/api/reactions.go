@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errUnknownReaction = errors.New("unknown reaction kind")
+
+// AddReaction godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Reacts to a post with the given kind. Reacting again with the same kind has no effect
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"
+//	@Success		204		{string}	string	"Reaction added"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [put]
+func (app *application) addReactionHandler(w http.ResponseWriter, r *http.Request) {
+	kind, ok := app.getReactionKind(r)
+	if !ok {
+		app.badRequestError(w, errUnknownReaction)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.Reactions.Add(r.Context(), post.ID, user.ID, kind); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveReaction godoc
+//
+//	@Summary		Removes a reaction from a post
+//	@Description	Removes the authenticated user's reaction of the given kind from a post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"
+//	@Success		204		{string}	string	"Reaction removed"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [delete]
+func (app *application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	kind, ok := app.getReactionKind(r)
+	if !ok {
+		app.badRequestError(w, errUnknownReaction)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.Reactions.Remove(r.Context(), post.ID, user.ID, kind); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getReactionKind returns the reaction kind in the URL, and whether it is one
+// of the configured kinds.
+func (app *application) getReactionKind(r *http.Request) (string, bool) {
+	kind := chi.URLParam(r, "kind")
+
+	return kind, slices.Contains(app.config.reactions.kinds, kind)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dylan-Oleary/go-social/internal/store"
+)
+
+// searchHandler godoc
+//
+//	@Summary		Searches posts, comments and users
+//	@Description	Full-text search ranked by relevance. Supports quoted phrases, "or" and "-" to exclude terms. Users who have blocked each other don't find each other or each other's content.
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query		string	true	"Search terms"
+//	@Param			type	query		string	false	"What to search: posts, comments or users"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	[]store.SearchResult
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.SearchQuery{
+		Type:  "posts",
+		Limit: 20,
+	}
+
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	results, cursors, err := app.store.Search.Search(r.Context(), user.ID, sq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.paginatedJSONResponse(w, http.StatusOK, results, cursors); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
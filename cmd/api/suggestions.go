@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Dylan-Oleary/go-social/internal/store"
+)
+
+// GetFollowSuggestions godoc
+//
+//	@Summary		Fetches who to follow
+//	@Description	Fetches users the authenticated user might want to follow, ranked by mutual follows, shared tags and recent activity. Suggestions are recomputed periodically, so new users may get none at first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Success		200		{object}	[]store.Suggestion
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/suggestions [get]
+func (app *application) getFollowSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.SuggestionsQuery{
+		Limit: 10,
+	}
+
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	suggestions, err := app.store.Suggestions.List(r.Context(), user.ID, sq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
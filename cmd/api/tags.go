@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dylan-Oleary/go-social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var errInvalidTag = errors.New("invalid tag")
+
+// getTrendingTagsHandler godoc
+//
+//	@Summary		Fetches trending tags
+//	@Description	Fetches the tags used most above their usual rate within a window, as of the last refresh
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	query		string	false	"Window: 1h, 24h or 7d"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tq := store.TrendingTagsQuery{
+		Window: "24h",
+		Limit:  10,
+	}
+
+	tq, err := tq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(tq); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	tags, err := app.store.Tags.GetTrending(r.Context(), tq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follows a tag
+//	@Description	Adds posts with the tag to the authenticated user's feed. Following it again has no effect
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag followed"
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [put]
+func (app *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := getTagParam(r)
+	if !ok {
+		app.badRequestError(w, errInvalidTag)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.Tags.Follow(r.Context(), user.ID, tag); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollows a tag
+//	@Description	Stops adding posts with the tag to the authenticated user's feed
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag unfollowed"
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [delete]
+func (app *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := getTagParam(r)
+	if !ok {
+		app.badRequestError(w, errInvalidTag)
+		return
+	}
+
+	user := getAuthUserFromCtx(r)
+
+	if err := app.store.Tags.Unfollow(r.Context(), user.ID, tag); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getTagParam returns the tag in the URL normalized the way post tags are,
+// and whether it is a valid tag at all.
+func getTagParam(r *http.Request) (string, bool) {
+	tags := normalizeTags([]string{chi.URLParam(r, "tag")})
+	if len(tags) == 0 {
+		return "", false
+	}
+
+	return tags[0], true
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/Dylan-Oleary/go-social/internal/follow"
 	"github.com/Dylan-Oleary/go-social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
 
+var errPrivateUser = errors.New("this user's follows are not visible to you")
+
 type userKey string
 
 const userCtxKey userKey = "user"
@@ -20,7 +24,7 @@ const userCtxKey userKey = "user"
 //	@Tags			users
 //	@Produce		json
 //	@Param			token	path		string	true	"Invitation Token"
-//	@Success		204		{string}	string "User activated"
+//	@Success		204		{string}	string	"User activated"
 //	@Failure		400		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
@@ -68,47 +72,98 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-type FollowUser struct {
-	UserID int64 `json:"user_id"`
+// UpdateUserPayload changes the authenticated user's settings. Fields left out
+// are unchanged.
+type UpdateUserPayload struct {
+	IsPrivate *bool `json:"is_private"`
+}
+
+// UpdateUser godoc
+//
+//	@Summary		Updates the authenticated user
+//	@Description	Updates the authenticated user's settings. A private user's posts are only shown to their approved followers
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateUserPayload	true	"User payload"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getAuthUserFromCtx(r)
+
+	if payload.IsPrivate != nil {
+		if err := app.store.Users.SetPrivate(ctx, user.ID, *payload.IsPrivate); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	user, err := app.store.Users.GetByID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
 
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Follows a user by ID
+//	@Description	Follows a user by ID, or asks to when they are private. Following a user again returns the existing follow or request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			userID	path		int		true	"User ID"
-//	@Success		204		{string}	string	"User followed"
-//	@Failure		400		{object}	error	"User payload missing"
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.FollowState
+//	@Failure		400		{object}	error	"Cannot follow yourself"
+//	@Failure		401		{object}	error	"Unauthorized"
+//	@Failure		403		{object}	error	"User blocked"
 //	@Failure		404		{object}	error	"User not found"
+//	@Failure		422		{object}	error	"User not active"
+//	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
 	userToFollow := getUserFromCtx(r)
+	follower := getAuthUserFromCtx(r)
 
-	// TODO: Revert when using Auth
-	var payload FollowUser
-	if err := readJSON(w, r, &payload); err != nil {
-		app.badRequestError(w, err)
-		return
-	}
-
-	if err := app.store.Followers.Follow(r.Context(), userToFollow.ID, payload.UserID); err != nil {
+	state, err := app.follows.Follow(r.Context(), userToFollow.ID, follower.ID)
+	if err != nil {
 		switch err {
-		case store.ErrConflict:
-			app.conflictError(w, err)
+		case follow.ErrSelfFollow:
+			app.badRequestError(w, err)
+		case follow.ErrBlocked:
+			app.forbiddenError(w, err)
+		case follow.ErrNotFound:
+			app.notFoundError(w, err)
+		case follow.ErrUserInactive:
+			app.unprocessableEntityError(w, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
-	type status struct {
-		OK bool `json:"ok"`
-	}
-	if err := app.jsonResponse(w, http.StatusOK, &status{OK: true}); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, state); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -116,35 +171,123 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser gdoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by ID
+//	@Description	Unfollow a user by ID, or withdraw the request to follow them. Unfollowing a user who isn't followed has no effect
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path		int		true	"User ID"
 //	@Success		204		{string}	string	"User unfollowed"
-//	@Failure		400		{object}	error	"User payload missing"
+//	@Failure		400		{object}	error	"Cannot unfollow yourself"
+//	@Failure		401		{object}	error	"Unauthorized"
 //	@Failure		404		{object}	error	"User not found"
+//	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/follow [delete]
 //	@Router			/users/{userID}/unfollow [put]
 func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
 	userToUnfollow := getUserFromCtx(r)
+	follower := getAuthUserFromCtx(r)
 
-	// TODO: Revert when using Auth
-	var payload FollowUser
-	if err := readJSON(w, r, &payload); err != nil {
+	if err := app.follows.Unfollow(r.Context(), userToUnfollow.ID, follower.ID); err != nil {
+		switch err {
+		case follow.ErrSelfFollow:
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFollowers godoc
+//
+//	@Summary		Fetches a user's followers
+//	@Description	Fetches the users following a user, most recent follow first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	[]store.FollowListUser
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.ListFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		Fetches who a user follows
+//	@Description	Fetches the users a user follows, most recent follow first
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	[]store.FollowListUser
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.Followers.ListFollowing)
+}
+
+// listFollows responds with a page from list for the user in the URL. A
+// private user's follows are only listed for their followers.
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, list func(context.Context, int64, store.PaginationCursorQuery) ([]store.FollowListUser, store.PageCursors, error)) {
+	fq := store.PaginationCursorQuery{
+		Limit: 20,
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestError(w, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
 		app.badRequestError(w, err)
 		return
 	}
 
-	if err := app.store.Followers.Unfollow(r.Context(), userToUnfollow.ID, payload.UserID); err != nil {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	visible, err := app.store.Users.IsVisibleTo(ctx, user.ID, getAuthUserFromCtx(r).ID)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	type status struct {
-		OK bool `json:"ok"`
+	if !visible {
+		app.forbiddenError(w, errPrivateUser)
+		return
+	}
+
+	users, cursors, err := list(ctx, user.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestError(w, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, &status{OK: true}); err != nil {
+	if err := app.paginatedJSONResponse(w, http.StatusOK, users, cursors); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS password_reset_at;
+
+DROP TABLE IF EXISTS password_resets;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_user_invitations_user_id;
+
+ALTER TABLE users
+DROP COLUMN invited_at;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE users
+ADD COLUMN invited_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT now();
+
+UPDATE users SET invited_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_user_invitations_user_id ON user_invitations (user_id);
+
+COMMIT;
\ No newline at end of file
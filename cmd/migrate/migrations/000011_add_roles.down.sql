@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_role_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role_id;
+
+DROP TABLE IF EXISTS roles;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    name varchar(255) UNIQUE NOT NULL,
+    level int NOT NULL DEFAULT 0,
+    description text NOT NULL
+);
+
+INSERT INTO roles (name, level, description)
+VALUES
+    ('user', 1, 'A user can create posts and comments, and edit or delete their own'),
+    ('moderator', 2, 'A moderator can delete other users posts and comments'),
+    ('admin', 3, 'An admin can edit and delete other users posts and comments');
+
+ALTER TABLE users
+ADD COLUMN role_id bigint NOT NULL DEFAULT 1;
+
+ALTER TABLE users
+ADD CONSTRAINT fk_role_id FOREIGN KEY (role_id) REFERENCES roles(id);
+
+COMMIT;
\ No newline at end of file
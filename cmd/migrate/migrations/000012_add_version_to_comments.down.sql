@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE comments
+DROP COLUMN version;
+
+COMMIT;
\ No newline at end of file
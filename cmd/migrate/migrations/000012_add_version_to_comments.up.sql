@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE comments
+ADD COLUMN version INT DEFAULT 0;
+
+COMMIT;
\ No newline at end of file
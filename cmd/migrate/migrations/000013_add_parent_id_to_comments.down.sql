@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_comments_parent_id;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_parent_id;
+
+ALTER TABLE comments
+DROP COLUMN parent_id,
+DROP COLUMN deleted_at;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE comments
+ADD COLUMN parent_id bigint,
+ADD COLUMN deleted_at timestamp(0) WITH TIME ZONE;
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_parent_id FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_posts_fanout_on_read;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS fanout_on_read;
+
+DROP TABLE IF EXISTS timelines;
+
+COMMIT;
\ No newline at end of file
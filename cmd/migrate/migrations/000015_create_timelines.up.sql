@@ -0,0 +1,35 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS timelines (
+    user_id bigint NOT NULL,
+    post_id bigint NOT NULL,
+    author_id bigint NOT NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL,
+
+    PRIMARY KEY (user_id, post_id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_post_id FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_timelines_user_id_created_at ON timelines (user_id, created_at DESC, post_id DESC);
+CREATE INDEX IF NOT EXISTS idx_timelines_post_id ON timelines (post_id);
+
+-- Posts are read on request until fanned out, and stay that way for authors
+-- with too many followers to fan out to
+ALTER TABLE posts
+ADD COLUMN fanout_on_read boolean NOT NULL DEFAULT false;
+
+ALTER TABLE posts
+ALTER COLUMN fanout_on_read SET DEFAULT true;
+
+CREATE INDEX IF NOT EXISTS idx_posts_fanout_on_read ON posts (user_id, created_at) WHERE fanout_on_read;
+
+INSERT INTO timelines (user_id, post_id, author_id, created_at)
+SELECT p.user_id, p.id, p.user_id, p.created_at
+FROM posts p
+UNION
+SELECT f.follower_id, p.id, p.user_id, p.created_at
+FROM posts p
+JOIN followers f ON f.user_id = p.user_id;
+
+COMMIT;
\ No newline at end of file
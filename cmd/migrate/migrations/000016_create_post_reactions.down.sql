@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS reaction_counts;
+
+DROP TABLE IF EXISTS post_reactions;
+
+COMMIT;
\ No newline at end of file
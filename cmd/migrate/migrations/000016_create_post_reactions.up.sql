@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS post_reactions (
+    post_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    kind varchar(32) NOT NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (post_id, user_id, kind),
+    CONSTRAINT fk_post_id FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reactions_user_id ON post_reactions (user_id);
+
+ALTER TABLE posts
+ADD COLUMN reaction_counts jsonb NOT NULL DEFAULT '{}';
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_posts_quote_of_id;
+DROP INDEX IF EXISTS idx_posts_repost_of_id;
+DROP INDEX IF EXISTS idx_posts_user_id_repost_of_id;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS repost_count,
+DROP COLUMN IF EXISTS quote_of_id,
+DROP COLUMN IF EXISTS repost_of_id;
+
+COMMIT;
\ No newline at end of file
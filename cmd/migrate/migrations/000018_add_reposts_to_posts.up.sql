@@ -0,0 +1,17 @@
+BEGIN;
+
+ALTER TABLE posts
+ADD COLUMN repost_of_id bigint,
+ADD COLUMN quote_of_id bigint,
+ADD COLUMN repost_count int NOT NULL DEFAULT 0;
+
+ALTER TABLE posts
+ADD CONSTRAINT fk_repost_of_id FOREIGN KEY (repost_of_id) REFERENCES posts(id) ON DELETE CASCADE,
+ADD CONSTRAINT fk_quote_of_id FOREIGN KEY (quote_of_id) REFERENCES posts(id) ON DELETE SET NULL;
+
+-- A user can only repost a post once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_id_repost_of_id ON posts (user_id, repost_of_id) WHERE repost_of_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_repost_of_id ON posts (repost_of_id);
+CREATE INDEX IF NOT EXISTS idx_posts_quote_of_id ON posts (quote_of_id);
+
+COMMIT;
\ No newline at end of file
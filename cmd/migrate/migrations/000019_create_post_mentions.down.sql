@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS post_mentions;
+
+COMMIT;
\ No newline at end of file
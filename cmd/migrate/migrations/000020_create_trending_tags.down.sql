@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_posts_created_at;
+DROP TABLE IF EXISTS trending_tags;
+
+COMMIT;
\ No newline at end of file
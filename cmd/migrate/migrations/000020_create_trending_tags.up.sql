@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS trending_tags (
+    time_window varchar(8) NOT NULL,
+    tag varchar(255) NOT NULL,
+    post_count int NOT NULL,
+    baseline real NOT NULL,
+    score real NOT NULL,
+    computed_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (time_window, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trending_tags_time_window_score ON trending_tags (time_window, score DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS tag_follows;
+
+COMMIT;
\ No newline at end of file
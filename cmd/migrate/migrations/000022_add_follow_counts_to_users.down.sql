@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_followers_follower_id_created_at;
+DROP INDEX IF EXISTS idx_followers_user_id_created_at;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS posts_count,
+DROP COLUMN IF EXISTS following_count,
+DROP COLUMN IF EXISTS followers_count;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,17 @@
+BEGIN;
+
+ALTER TABLE users
+ADD COLUMN followers_count int NOT NULL DEFAULT 0,
+ADD COLUMN following_count int NOT NULL DEFAULT 0,
+ADD COLUMN posts_count int NOT NULL DEFAULT 0;
+
+UPDATE users u
+SET
+    followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id),
+    following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+    posts_count = (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id);
+
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers (user_id, created_at, follower_id);
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id_created_at ON followers (follower_id, created_at, user_id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS follow_requests;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS is_private;
+
+COMMIT;
\ No newline at end of file
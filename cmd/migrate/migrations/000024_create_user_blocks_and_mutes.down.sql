@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS user_mutes;
+DROP TABLE IF EXISTS user_blocks;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS follow_suggestions;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS suggestions_refreshed_at;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,24 @@
+BEGIN;
+
+ALTER TABLE users
+ADD COLUMN suggestions_refreshed_at timestamp(0) WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS follow_suggestions (
+    user_id bigint NOT NULL,
+    suggested_id bigint NOT NULL,
+    mutual_count int NOT NULL,
+    shared_tag_count int NOT NULL,
+    recent_post_count int NOT NULL,
+    score real NOT NULL,
+    computed_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (user_id, suggested_id),
+    CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_suggested_id FOREIGN KEY (suggested_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_suggestions_user_id_score ON follow_suggestions (user_id, score DESC);
+CREATE INDEX IF NOT EXISTS idx_follow_suggestions_suggested_id ON follow_suggestions (suggested_id);
+CREATE INDEX IF NOT EXISTS idx_users_suggestions_refreshed_at ON users (suggestions_refreshed_at NULLS FIRST, id);
+
+COMMIT;
\ No newline at end of file
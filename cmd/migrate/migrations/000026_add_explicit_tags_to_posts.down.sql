@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS explicit_tags;
+
+COMMIT;
\ No newline at end of file
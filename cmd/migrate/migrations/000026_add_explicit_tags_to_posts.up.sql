@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Tags the author gave explicitly, so that a post's tags can be rebuilt from
+-- them and its current hashtags when it is edited
+ALTER TABLE posts
+ADD COLUMN explicit_tags varchar(100)[] NOT NULL DEFAULT '{}';
+
+UPDATE posts p
+SET explicit_tags = ARRAY(
+    SELECT t.tag
+    FROM unnest(p.tags) AS t (tag)
+    WHERE strpos(lower(p.content), '#' || t.tag) = 0
+)
+WHERE p.tags IS NOT NULL;
+
+COMMIT;
\ No newline at end of file
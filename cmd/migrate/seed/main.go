@@ -22,6 +22,6 @@ func main() {
 
 	defer conn.Close()
 
-	store := store.NewStorage(conn)
+	store := store.NewStorage(conn, env.GetString("PAGINATION_CURSOR_SECRET", "example"))
 	db.Seed(store, conn)
 }
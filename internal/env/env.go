@@ -3,6 +3,8 @@ package env
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lpernett/godotenv"
 )
@@ -36,3 +38,42 @@ func GetInt(key string, fallback int) int {
 
 	return valAsInt
 }
+
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+
+	if !ok {
+		return fallback
+	}
+
+	valAsDuration, err := time.ParseDuration(val)
+
+	if err != nil {
+		return fallback
+	}
+
+	return valAsDuration
+}
+
+// GetList splits a comma separated value, trimming each entry and dropping
+// empty ones.
+func GetList(key string, fallback []string) []string {
+	val, ok := os.LookupEnv(key)
+
+	if !ok {
+		return fallback
+	}
+
+	var list []string
+	for _, entry := range strings.Split(val, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	if len(list) == 0 {
+		return fallback
+	}
+
+	return list
+}
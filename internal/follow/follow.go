@@ -0,0 +1,80 @@
+package follow
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Dylan-Oleary/go-social/internal/store"
+)
+
+var (
+	ErrBlocked         = errors.New("one of the users has blocked the other")
+	ErrNotFound        = errors.New("user not found")
+	ErrRequestNotFound = errors.New("follow request not found")
+	ErrSelfFollow      = errors.New("users cannot follow themselves")
+	ErrUserInactive    = errors.New("user is not active")
+)
+
+// Service follows and unfollows users and resolves follow requests, turning
+// what the store reports into the errors above.
+type Service struct {
+	store store.Storage
+}
+
+func NewService(store store.Storage) *Service {
+	return &Service{store: store}
+}
+
+// Follow follows the user, or asks to when they are private, and returns
+// where the follower stands with them. Following a user again leaves the
+// existing follow or request as it is.
+func (s *Service) Follow(ctx context.Context, userId int64, followerId int64) (*store.FollowState, error) {
+	if userId == followerId {
+		return nil, ErrSelfFollow
+	}
+
+	state, err := s.store.Followers.Follow(ctx, userId, followerId)
+	if err != nil {
+		switch err {
+		case store.ErrBlocked:
+			return nil, ErrBlocked
+		case store.ErrNotFound:
+			return nil, ErrNotFound
+		case store.ErrUserInactive:
+			return nil, ErrUserInactive
+		default:
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+// Unfollow removes the follow, or withdraws the request to follow the user.
+// Unfollowing a user who isn't followed has no effect.
+func (s *Service) Unfollow(ctx context.Context, userId int64, followerId int64) error {
+	if userId == followerId {
+		return ErrSelfFollow
+	}
+
+	return s.store.Followers.Unfollow(ctx, userId, followerId)
+}
+
+// ApproveRequest makes the requester a follower of the user.
+func (s *Service) ApproveRequest(ctx context.Context, userId int64, requestId int64) error {
+	return requestError(s.store.Followers.ApproveRequest(ctx, userId, requestId))
+}
+
+// RejectRequest drops one of the user's pending follow requests.
+func (s *Service) RejectRequest(ctx context.Context, userId int64, requestId int64) error {
+	return requestError(s.store.Followers.RejectRequest(ctx, userId, requestId))
+}
+
+func requestError(err error) error {
+	switch err {
+	case store.ErrNotFound:
+		return ErrRequestNotFound
+	default:
+		return err
+	}
+}
@@ -3,9 +3,10 @@ package mailer
 import "embed"
 
 const (
-	MailFromName        = "Go-Social"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	MailFromName          = "Go-Social"
+	maxRetries            = 3
+	PasswordResetTemplate = "password_reset.tmpl"
+	UserWelcomeTemplate   = "user_invitation.tmpl"
 )
 
 //go:embed "templates"
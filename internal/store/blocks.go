@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type BlockStore struct {
+	db        *sql.DB
+	followers *FollowersStore
+}
+
+// Block stops the two users from seeing or following each other, and removes
+// any follows and follow requests between them. Blocking again has no effect.
+func (s *BlockStore) Block(ctx context.Context, userId int64, blockedId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, userId, blockedId); err != nil {
+			return err
+		}
+
+		if err := s.followers.unfollow(ctx, tx, userId, blockedId); err != nil {
+			return err
+		}
+
+		return s.followers.unfollow(ctx, tx, blockedId, userId)
+	})
+}
+
+// Unblock lifts the block. Follows removed by it are not restored.
+func (s *BlockStore) Unblock(ctx context.Context, userId int64, blockedId int64) error {
+	query := `DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, blockedId)
+	return err
+}
+
+func (s *BlockStore) create(ctx context.Context, tx *sql.Tx, userId int64, blockedId int64) error {
+	query := `
+        INSERT INTO user_blocks (user_id, blocked_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId, blockedId)
+	return err
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Bookmark is a post saved by a user, shown the same way as in the feed.
+type Bookmark struct {
+	PostWithMetadata
+	BookmarkedAt string `json:"bookmarked_at"`
+
+	bookmarkId int64
+}
+
+type BookmarkStore struct {
+	db      *sql.DB
+	cursors cursorCodec
+}
+
+// Add bookmarks the post for the user. Bookmarking it again is a no-op.
+func (s *BookmarkStore) Add(ctx context.Context, postId int64, userId int64) error {
+	query := `
+        INSERT INTO bookmarks (user_id, post_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, postId)
+	return err
+}
+
+// Remove deletes the user's bookmark of the post, if there is one.
+func (s *BookmarkStore) Remove(ctx context.Context, postId int64, userId int64) error {
+	query := `
+        DELETE FROM bookmarks b
+        WHERE b.user_id = $1 AND b.post_id = $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, postId)
+	return err
+}
+
+// List returns a page of the user's bookmarks, most recently bookmarked first.
+func (s *BookmarkStore) List(ctx context.Context, userId int64, bq PaginationCursorQuery) ([]Bookmark, PageCursors, error) {
+	var c cursor
+	if bq.Cursor != "" {
+		var err error
+		if c, err = s.cursors.decode(bq.Cursor, cursorByCreatedAt); err != nil {
+			return nil, PageCursors{}, err
+		}
+	}
+
+	sort := "desc"
+	if c.Prev {
+		sort = reverseSort(sort)
+	}
+
+	args := []interface{}{userId, bq.Limit + 1}
+
+	query := `
+        SELECT` + feedColumns + `,
+            b.id, b.created_at
+        FROM bookmarks b
+        JOIN posts p ON p.id = b.post_id` + feedJoins + `
+        WHERE b.user_id = $1 AND ` + visibleTo("u", "$1") + ` AND ` + visibleTo("ru", "$1") + `
+    `
+
+	// Keyset
+	if bq.Cursor != "" {
+		query += keysetCondition("b", "desc", c, len(args)+1)
+		args = append(args, c.Key, c.ID)
+	}
+
+	query += `
+        GROUP BY b.id, ` + feedGroupBy + `
+        ORDER BY b.created_at ` + sort + `, b.id ` + sort + `
+        LIMIT $2;
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	defer rows.Close()
+
+	bookmarks := []Bookmark{}
+	for rows.Next() {
+		var b Bookmark
+
+		if err := scanFeedRow(rows, &b.PostWithMetadata, &b.bookmarkId, &b.BookmarkedAt); err != nil {
+			return nil, PageCursors{}, err
+		}
+
+		bookmarks = append(bookmarks, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	bookmarks, cursors := keysetPage(s.cursors, bookmarks, bq.Limit, c.Prev, bq.Cursor != "", func(b Bookmark) (string, int64) {
+		return b.BookmarkedAt, b.bookmarkId
+	})
+
+	return bookmarks, cursors, nil
+}
@@ -3,71 +3,234 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	User      User   `json:"user"`
+	ID         int64   `json:"id"`
+	PostID     int64   `json:"post_id"`
+	UserID     int64   `json:"user_id"`
+	ParentID   *int64  `json:"parent_id"`
+	Content    string  `json:"content"`
+	Version    int     `json:"version"`
+	IsDeleted  bool    `json:"is_deleted"`
+	Depth      int     `json:"depth"`
+	Path       []int64 `json:"path,omitempty"`
+	ReplyCount int     `json:"reply_count"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+	User       User    `json:"user"`
+}
+
+// unlessDeleted selects column for the comment c, or blank once it has been
+// tombstoned, so that a tombstone gives nothing away about its author.
+func unlessDeleted(column string, blank string) string {
+	return `CASE WHEN c.deleted_at IS NULL THEN ` + column + ` ELSE ` + blank + ` END`
 }
 
 type CommentStore struct {
-	db *sql.DB
+	db      *sql.DB
+	cursors cursorCodec
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByID returns the comment, unless its author and the viewer have blocked
+// each other.
+func (s *CommentStore) GetByID(ctx context.Context, id int64, viewerId int64) (*Comment, error) {
 	query := `
-        SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.updated_at, u.id, u.username
+        SELECT
+            c.id, c.post_id, ` + unlessDeleted("c.user_id", "0") + `, c.parent_id, c.content, c.version, c.deleted_at IS NOT NULL,
+            (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count,
+            c.created_at, c.updated_at, ` + unlessDeleted("u.id", "0") + `, ` + unlessDeleted("u.username", "''") + `
         FROM comments c
         JOIN users u on u.id = c.user_id
-        WHERE c.post_id = $1
-        ORDER BY c.created_at DESC
+        WHERE c.id = $1 AND NOT ` + blockedBetween("c.user_id", "$2") + `
     `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	var c Comment
+
+	err := s.db.QueryRowContext(ctx, query, id, viewerId).Scan(
+		&c.ID,
+		&c.PostID,
+		&c.UserID,
+		&c.ParentID,
+		&c.Content,
+		&c.Version,
+		&c.IsDeleted,
+		&c.ReplyCount,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.User.ID,
+		&c.User.Username,
+	)
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// List returns a page of top-level comments on a post, each followed by its
+// replies down to cq.Depth levels. Comments are ordered so that walking the
+// result in order yields the thread, and Path can be used to nest them.
+// Comments by users the viewer has blocked, or who blocked them, are left out
+// along with their replies. The returned cursor is empty once there are no
+// more top-level comments.
+func (s *CommentStore) List(ctx context.Context, postID int64, viewerId int64, cq PaginationCommentsQuery) ([]Comment, string, error) {
+	args := []interface{}{postID, cq.Limit + 1, cq.Depth, viewerId}
+
+	// Keyset
+	keyset := ""
+	if cq.Cursor != "" {
+		c, err := s.cursors.decode(cq.Cursor, cursorByCreatedAt)
+		if err != nil || c.Prev {
+			return nil, "", ErrInvalidCursor
+		}
+
+		keyset = keysetCondition("c", cq.Sort, c, len(args)+1)
+		args = append(args, c.Key, c.ID)
+	}
+
+	query := `
+        WITH RECURSIVE roots AS (
+            SELECT c.id, row_number() OVER (ORDER BY c.created_at ` + cq.Sort + `, c.id ` + cq.Sort + `) AS position
+            FROM comments c
+            WHERE c.post_id = $1 AND c.parent_id IS NULL AND NOT ` + blockedBetween("c.user_id", "$4") + keyset + `
+            ORDER BY position
+            LIMIT $2
+        ), thread AS (
+            SELECT r.id, r.position, 0 AS depth, ARRAY[r.id] AS path
+            FROM roots r
+            UNION ALL
+            SELECT c.id, t.position, t.depth + 1, t.path || c.id
+            FROM comments c
+            JOIN thread t ON c.parent_id = t.id
+            WHERE t.depth < $3 AND NOT ` + blockedBetween("c.user_id", "$4") + `
+        )
+        SELECT
+            t.position,
+            c.id, c.post_id, ` + unlessDeleted("c.user_id", "0") + `, c.parent_id, c.content, c.version, c.deleted_at IS NOT NULL,
+            t.depth, t.path,
+            (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count,
+            c.created_at, c.updated_at, ` + unlessDeleted("u.id", "0") + `, ` + unlessDeleted("u.username", "''") + `
+        FROM thread t
+        JOIN comments c ON c.id = t.id
+        JOIN users u on u.id = c.user_id
+        ORDER BY t.position, t.path
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	comments := []Comment{}
+	hasMore := false
 
 	for rows.Next() {
 		var c Comment
-		c.User = User{}
+		var position int
 
 		err := rows.Scan(
+			&position,
 			&c.ID,
 			&c.PostID,
 			&c.UserID,
+			&c.ParentID,
 			&c.Content,
+			&c.Version,
+			&c.IsDeleted,
+			&c.Depth,
+			pq.Array(&c.Path),
+			&c.ReplyCount,
 			&c.CreatedAt,
 			&c.UpdatedAt,
 			&c.User.ID,
 			&c.User.Username,
 		)
 		if err != nil {
-			return nil, err
+			return nil, "", err
+		}
+
+		// The extra root only tells us whether another page exists
+		if position > cq.Limit {
+			hasMore = true
+			continue
 		}
 
 		comments = append(comments, c)
 	}
 
-	return comments, nil
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if hasMore {
+		for i := len(comments) - 1; i >= 0; i-- {
+			if comments[i].ParentID == nil {
+				nextCursor = s.cursors.encode(newCreatedAtCursor(comments[i].CreatedAt, comments[i].ID, false))
+				break
+			}
+		}
+	}
+
+	return comments, nextCursor, nil
+}
+
+// GetDepth returns how many ancestors the comment has, so a top-level comment
+// has a depth of 0.
+func (s *CommentStore) GetDepth(ctx context.Context, id int64) (int, error) {
+	query := `
+        WITH RECURSIVE ancestors AS (
+            SELECT c.id, c.parent_id, 0 AS depth
+            FROM comments c
+            WHERE c.id = $1
+            UNION ALL
+            SELECT c.id, c.parent_id, a.depth + 1
+            FROM comments c
+            JOIN ancestors a ON c.id = a.parent_id
+        )
+        SELECT COALESCE(MAX(depth), -1) FROM ancestors
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var depth int
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&depth); err != nil {
+		return 0, err
+	}
+
+	if depth < 0 {
+		return 0, ErrNotFound
+	}
+
+	return depth, nil
 }
 
+// Create adds the comment, returning ErrBlocked when the post's author and
+// the commenter have blocked each other.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	query := `
-        INSERT INTO comments (content, post_id, user_id)
-        VALUES ($1, $2, $3)
-        RETURNING id, created_at, updated_at
+        INSERT INTO comments (content, post_id, user_id, parent_id)
+        SELECT $1, p.id, $3, $4
+        FROM posts p
+        WHERE p.id = $2 AND NOT ` + blockedBetween("p.user_id", "$3::bigint") + `
+        RETURNING id, version, created_at, updated_at
     `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -79,14 +242,182 @@ func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 		comment.Content,
 		comment.PostID,
 		comment.UserID,
+		comment.ParentID,
 	).Scan(
 		&comment.ID,
+		&comment.Version,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
 	)
 
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrBlocked
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteByID removes a comment. A comment that has replies is tombstoned
+// instead, so that the rest of the thread stays intact, and tombstones left
+// without replies by the removal are removed too.
+func (s *CommentStore) DeleteByID(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// Parents are locked before their replies go, so that removing the
+		// last replies concurrently can't leave a tombstone behind
+		parentId, err := s.lockParent(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		removed, err := s.deleteOrTombstone(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		for removed && parentId != nil {
+			id := *parentId
+
+			parentId, err = s.lockParent(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+
+			removed, err = s.deleteTombstone(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// lockParent locks the parent of the comment and returns its ID, which is nil
+// for a top-level comment.
+func (s *CommentStore) lockParent(ctx context.Context, tx *sql.Tx, id int64) (*int64, error) {
+	query := `
+        SELECT p.id
+        FROM comments c
+        JOIN comments p ON p.id = c.parent_id
+        WHERE c.id = $1
+        FOR UPDATE OF p
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var parentId int64
+	err := tx.QueryRowContext(ctx, query, id).Scan(&parentId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &parentId, nil
+}
+
+// deleteOrTombstone removes the comment, or tombstones it if it has replies,
+// and reports whether it was removed.
+func (s *CommentStore) deleteOrTombstone(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	query := `
+        WITH tombstoned AS (
+            UPDATE comments c
+            SET content = '', deleted_at = now(), version = c.version + 1, updated_at = now()
+            WHERE c.id = $1
+            AND c.deleted_at IS NULL
+            AND EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+            RETURNING c.id
+        ), deleted AS (
+            DELETE FROM comments c
+            WHERE c.id = $1
+            AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+            RETURNING c.id
+        )
+        SELECT (SELECT COUNT(*) FROM tombstoned), (SELECT COUNT(*) FROM deleted)
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var tombstoned, deleted int64
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&tombstoned, &deleted); err != nil {
+		return false, err
+	}
+
+	if tombstoned+deleted == 0 {
+		return false, ErrNotFound
+	}
+
+	return deleted > 0, nil
+}
+
+// deleteTombstone removes the comment if it is a tombstone without replies,
+// and reports whether it was removed.
+func (s *CommentStore) deleteTombstone(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	query := `
+        DELETE FROM comments c
+        WHERE c.id = $1
+        AND c.deleted_at IS NOT NULL
+        AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// Update saves the comment only if its version still matches the stored one,
+// returning ErrEditConflict when another write got there first.
+func (s *CommentStore) Update(ctx context.Context, c *Comment) error {
+	query := `
+        UPDATE comments c
+        SET content = $2, version = c.version + 1, updated_at = now()
+        WHERE c.id = $1
+        AND c.version = $3
+        AND c.deleted_at IS NULL
+        RETURNING c.version, c.updated_at
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		c.ID,
+		c.Content,
+		c.Version,
+	).Scan(
+		&c.Version,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// FollowRequest is a pending request from a user to follow a private user.
+type FollowRequest struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ListRequests returns a page of the pending requests to follow the user,
+// most recent first.
+func (s *FollowersStore) ListRequests(ctx context.Context, userId int64, fq PaginationCursorQuery) ([]FollowRequest, PageCursors, error) {
+	var c cursor
+	if fq.Cursor != "" {
+		var err error
+		if c, err = s.cursors.decode(fq.Cursor, cursorByCreatedAt); err != nil {
+			return nil, PageCursors{}, err
+		}
+	}
+
+	sort := "desc"
+	if c.Prev {
+		sort = reverseSort(sort)
+	}
+
+	args := []interface{}{userId, fq.Limit + 1}
+
+	query := `
+        SELECT fr.id, u.id, u.username, fr.created_at
+        FROM follow_requests fr
+        JOIN users u ON u.id = fr.requester_id
+        WHERE fr.user_id = $1
+    `
+
+	// Keyset
+	if fq.Cursor != "" {
+		query += keysetCondition("fr", "desc", c, len(args)+1)
+		args = append(args, c.Key, c.ID)
+	}
+
+	query += `
+        ORDER BY fr.created_at ` + sort + `, fr.id ` + sort + `
+        LIMIT $2;
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var request FollowRequest
+		if err := rows.Scan(&request.ID, &request.UserID, &request.Username, &request.CreatedAt); err != nil {
+			return nil, PageCursors{}, err
+		}
+
+		requests = append(requests, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	requests, cursors := keysetPage(s.cursors, requests, fq.Limit, c.Prev, fq.Cursor != "", func(fr FollowRequest) (string, int64) {
+		return fr.CreatedAt, fr.ID
+	})
+
+	return requests, cursors, nil
+}
+
+// ApproveRequest turns one of the user's pending follow requests into a
+// follow.
+func (s *FollowersStore) ApproveRequest(ctx context.Context, userId int64, requestId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		requesterId, err := s.deleteRequestByID(ctx, tx, userId, requestId)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.follow(ctx, tx, userId, requesterId)
+		return err
+	})
+}
+
+// RejectRequest drops one of the user's pending follow requests.
+func (s *FollowersStore) RejectRequest(ctx context.Context, userId int64, requestId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		_, err := s.deleteRequestByID(ctx, tx, userId, requestId)
+		return err
+	})
+}
+
+// createRequest asks to follow the user, unless the follower has already
+// asked, and returns when the request was made.
+func (s *FollowersStore) createRequest(ctx context.Context, tx *sql.Tx, userId int64, requesterId int64) (string, error) {
+	query := `
+        WITH inserted AS (
+            INSERT INTO follow_requests (user_id, requester_id)
+            VALUES ($1, $2)
+            ON CONFLICT DO NOTHING
+            RETURNING created_at
+        )
+        SELECT created_at FROM inserted
+        UNION ALL
+        SELECT fr.created_at FROM follow_requests fr WHERE fr.user_id = $1 AND fr.requester_id = $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var requestedAt string
+	err := tx.QueryRowContext(ctx, query, userId, requesterId).Scan(&requestedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// A concurrent request committed after this statement's snapshot
+			// was taken, so neither half saw it; a new statement will.
+			return s.getRequestedAt(ctx, tx, userId, requesterId)
+		default:
+			return "", err
+		}
+	}
+
+	return requestedAt, nil
+}
+
+func (s *FollowersStore) getRequestedAt(ctx context.Context, tx *sql.Tx, userId int64, requesterId int64) (string, error) {
+	query := `SELECT fr.created_at FROM follow_requests fr WHERE fr.user_id = $1 AND fr.requester_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var requestedAt string
+	err := tx.QueryRowContext(ctx, query, userId, requesterId).Scan(&requestedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return requestedAt, nil
+}
+
+// deleteRequestByID deletes the user's follow request and returns who made it.
+func (s *FollowersStore) deleteRequestByID(ctx context.Context, tx *sql.Tx, userId int64, requestId int64) (int64, error) {
+	query := `
+        DELETE FROM follow_requests fr
+        WHERE fr.id = $1 AND fr.user_id = $2
+        RETURNING fr.requester_id
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var requesterId int64
+	err := tx.QueryRowContext(ctx, query, requestId, userId).Scan(&requesterId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return requesterId, nil
+}
+
+// deleteRequest withdraws the requester's pending request to follow the user,
+// if there is one.
+func (s *FollowersStore) deleteRequest(ctx context.Context, tx *sql.Tx, userId int64, requesterId int64) error {
+	query := `
+        DELETE FROM follow_requests fr
+        WHERE fr.user_id = $1 AND fr.requester_id = $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId, requesterId)
+	return err
+}
+
+// checkTarget reports whether the user the follower wants to follow is
+// private, returning ErrUserInactive when they haven't activated their
+// account and ErrBlocked when either has blocked the other.
+func (s *FollowersStore) checkTarget(ctx context.Context, tx *sql.Tx, userId int64, followerId int64) (bool, error) {
+	query := `SELECT u.is_active, u.is_private, ` + blockedBetween("u.id", "$2") + ` FROM users u WHERE u.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var active, private, blocked bool
+	err := tx.QueryRowContext(ctx, query, userId, followerId).Scan(&active, &private, &blocked)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrNotFound
+		default:
+			return false, err
+		}
+	}
+
+	if !active {
+		return false, ErrUserInactive
+	}
+
+	if blocked {
+		return false, ErrBlocked
+	}
+
+	return private, nil
+}
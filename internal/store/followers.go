@@ -3,8 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
-
-	"github.com/lib/pq"
+	"errors"
 )
 
 type Follower struct {
@@ -13,27 +12,275 @@ type Follower struct {
 	CreatedAt  string `json:"created_at"`
 }
 
+// FollowListUser is a user in a followers or following list, along with when
+// the follow was made.
+type FollowListUser struct {
+	ID         int64  `json:"id"`
+	Username   string `json:"username"`
+	FollowedAt string `json:"followed_at"`
+}
+
+const (
+	FollowStatusFollowing = "following"
+	FollowStatusRequested = "requested"
+)
+
+// FollowState is where a follower stands with a user they asked to follow:
+// either following them since FollowedAt, or waiting on a request made at
+// RequestedAt.
+type FollowState struct {
+	Status      string `json:"status"`
+	FollowedAt  string `json:"followed_at,omitempty"`
+	RequestedAt string `json:"requested_at,omitempty"`
+}
+
 type FollowersStore struct {
-	db *sql.DB
+	db      *sql.DB
+	cursors cursorCodec
+}
+
+// Follow follows the user, or asks to when they are private, and returns
+// where the follower stands with them. Following a user again leaves the
+// existing follow or request as it is. Inactive users, and users they have
+// blocked or been blocked by, can't be followed.
+func (s *FollowersStore) Follow(ctx context.Context, userToFollowId int64, followerUserId int64) (*FollowState, error) {
+	state := &FollowState{Status: FollowStatusFollowing}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		private, err := s.checkTarget(ctx, tx, userToFollowId, followerUserId)
+		if err != nil {
+			return err
+		}
+
+		if !private {
+			state.FollowedAt, err = s.follow(ctx, tx, userToFollowId, followerUserId)
+			return err
+		}
+
+		// Followers from before the user went private keep their follow
+		state.FollowedAt, err = s.getFollowedAt(ctx, tx, userToFollowId, followerUserId)
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		state.Status = FollowStatusRequested
+		state.RequestedAt, err = s.createRequest(ctx, tx, userToFollowId, followerUserId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// Unfollow removes the follow along with the user's posts in the follower's
+// timeline, or withdraws the request to follow them. Unfollowing a user who
+// isn't followed has no effect.
+func (s *FollowersStore) Unfollow(ctx context.Context, userToUnfollowId int64, followerUserId int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.unfollow(ctx, tx, userToUnfollowId, followerUserId)
+	})
+}
+
+// ListFollowers returns a page of the users following the user, most recent
+// follow first.
+func (s *FollowersStore) ListFollowers(ctx context.Context, userId int64, fq PaginationCursorQuery) ([]FollowListUser, PageCursors, error) {
+	return s.list(ctx, userId, "user_id", "follower_id", fq)
 }
 
-func (s *FollowersStore) Follow(ctx context.Context, userToFollowId int64, followerUserId int64) error {
-	query := "INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)"
+// ListFollowing returns a page of the users the user follows, most recent
+// follow first.
+func (s *FollowersStore) ListFollowing(ctx context.Context, userId int64, fq PaginationCursorQuery) ([]FollowListUser, PageCursors, error) {
+	return s.list(ctx, userId, "follower_id", "user_id", fq)
+}
+
+// list pages through the follows whose column matches userId and returns the
+// users on their other side.
+func (s *FollowersStore) list(ctx context.Context, userId int64, column string, other string, fq PaginationCursorQuery) ([]FollowListUser, PageCursors, error) {
+	var c cursor
+	if fq.Cursor != "" {
+		var err error
+		if c, err = s.cursors.decode(fq.Cursor, cursorByCreatedAt); err != nil {
+			return nil, PageCursors{}, err
+		}
+	}
+
+	sort := "desc"
+	if c.Prev {
+		sort = reverseSort(sort)
+	}
+
+	args := []interface{}{userId, fq.Limit + 1}
+
+	query := `
+        SELECT u.id, u.username, f.created_at
+        FROM followers f
+        JOIN users u ON u.id = f.` + other + `
+        WHERE f.` + column + ` = $1
+    `
+
+	// Keyset
+	if fq.Cursor != "" {
+		query += keysetConditionOn("f.created_at", "f."+other, "desc", c, len(args)+1)
+		args = append(args, c.Key, c.ID)
+	}
+
+	query += `
+        ORDER BY f.created_at ` + sort + `, f.` + other + ` ` + sort + `
+        LIMIT $2;
+    `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userToFollowId, followerUserId)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrConflict
+		return nil, PageCursors{}, err
+	}
+
+	defer rows.Close()
+
+	users := []FollowListUser{}
+	for rows.Next() {
+		var user FollowListUser
+		if err := rows.Scan(&user.ID, &user.Username, &user.FollowedAt); err != nil {
+			return nil, PageCursors{}, err
 		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, PageCursors{}, err
 	}
 
-	return nil
+	users, cursors := keysetPage(s.cursors, users, fq.Limit, c.Prev, fq.Cursor != "", func(u FollowListUser) (string, int64) {
+		return u.FollowedAt, u.ID
+	})
+
+	return users, cursors, nil
 }
 
-func (s *FollowersStore) Unfollow(ctx context.Context, userToUnfollowId int64, followerUserId int64) error {
+// follow records the follow, counts it for both users and backfills the
+// follower's timeline with the user's recent posts. Both users are locked
+// first, so that a post being fanned out meanwhile is either fanned out to the
+// new follower or backfilled once it is done.
+func (s *FollowersStore) follow(ctx context.Context, tx *sql.Tx, userToFollowId int64, followerUserId int64) (string, error) {
+	if err := s.lockUsers(ctx, tx, userToFollowId, followerUserId); err != nil {
+		return "", err
+	}
+
+	followedAt, created, err := s.create(ctx, tx, userToFollowId, followerUserId)
+	if err != nil || !created {
+		return followedAt, err
+	}
+
+	if err := s.updateCounts(ctx, tx, userToFollowId, followerUserId, 1); err != nil {
+		return "", err
+	}
+
+	if err := s.backfillTimeline(ctx, tx, userToFollowId, followerUserId); err != nil {
+		return "", err
+	}
+
+	return followedAt, nil
+}
+
+// unfollow withdraws any request to follow the user, then removes the follow,
+// its counts and the user's posts in the follower's timeline.
+func (s *FollowersStore) unfollow(ctx context.Context, tx *sql.Tx, userToUnfollowId int64, followerUserId int64) error {
+	if err := s.lockUsers(ctx, tx, userToUnfollowId, followerUserId); err != nil {
+		return err
+	}
+
+	if err := s.deleteRequest(ctx, tx, userToUnfollowId, followerUserId); err != nil {
+		return err
+	}
+
+	deleted, err := s.delete(ctx, tx, userToUnfollowId, followerUserId)
+	if err != nil || !deleted {
+		return err
+	}
+
+	if err := s.updateCounts(ctx, tx, userToUnfollowId, followerUserId, -1); err != nil {
+		return err
+	}
+
+	return s.pruneTimeline(ctx, tx, userToUnfollowId, followerUserId)
+}
+
+// lockUsers locks both users ahead of updateCounts, in ID order so that two
+// users following each other at once wait on one another rather than
+// deadlock. The lock also conflicts with the share lock TimelineStore.FanOut
+// holds while fanning out a user's posts.
+func (s *FollowersStore) lockUsers(ctx context.Context, tx *sql.Tx, userId int64, otherId int64) error {
+	query := `SELECT id FROM users WHERE id IN ($1, $2) ORDER BY id FOR NO KEY UPDATE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId, otherId)
+	return err
+}
+
+// create records the follow unless it already exists, and returns when it was
+// made and whether it was made just now.
+func (s *FollowersStore) create(ctx context.Context, tx *sql.Tx, userToFollowId int64, followerUserId int64) (string, bool, error) {
+	query := `
+        WITH inserted AS (
+            INSERT INTO followers (user_id, follower_id)
+            VALUES ($1, $2)
+            ON CONFLICT DO NOTHING
+            RETURNING created_at
+        )
+        SELECT created_at, true FROM inserted
+        UNION ALL
+        SELECT f.created_at, false FROM followers f WHERE f.user_id = $1 AND f.follower_id = $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var followedAt string
+	var created bool
+	err := tx.QueryRowContext(ctx, query, userToFollowId, followerUserId).Scan(&followedAt, &created)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// A concurrent follow committed after this statement's snapshot was
+			// taken, so neither half saw it; a new statement will.
+			followedAt, err := s.getFollowedAt(ctx, tx, userToFollowId, followerUserId)
+			return followedAt, false, err
+		default:
+			return "", false, err
+		}
+	}
+
+	return followedAt, created, nil
+}
+
+func (s *FollowersStore) getFollowedAt(ctx context.Context, tx *sql.Tx, userId int64, followerId int64) (string, error) {
+	query := `SELECT f.created_at FROM followers f WHERE f.user_id = $1 AND f.follower_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var followedAt string
+	err := tx.QueryRowContext(ctx, query, userId, followerId).Scan(&followedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return followedAt, nil
+}
+
+func (s *FollowersStore) delete(ctx context.Context, tx *sql.Tx, userToUnfollowId int64, followerUserId int64) (bool, error) {
 	query := `
         DELETE FROM followers f
         WHERE f.user_id = $1
@@ -43,6 +290,67 @@ func (s *FollowersStore) Unfollow(ctx context.Context, userToUnfollowId int64, f
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userToUnfollowId, followerUserId)
+	res, err := tx.ExecContext(ctx, query, userToUnfollowId, followerUserId)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// updateCounts moves the user's followers count and the follower's following
+// count by delta.
+func (s *FollowersStore) updateCounts(ctx context.Context, tx *sql.Tx, userId int64, followerId int64, delta int) error {
+	query := `
+        UPDATE users u
+        SET
+            followers_count = u.followers_count + CASE WHEN u.id = $1 THEN $3 ELSE 0 END,
+            following_count = u.following_count + CASE WHEN u.id = $2 THEN $3 ELSE 0 END
+        WHERE u.id IN ($1, $2)
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId, followerId, delta)
+	return err
+}
+
+// backfillTimeline copies the author's most recent fanned out posts into the
+// follower's timeline. Posts still read on request need no copy.
+func (s *FollowersStore) backfillTimeline(ctx context.Context, tx *sql.Tx, authorId int64, followerId int64) error {
+	query := `
+        INSERT INTO timelines (user_id, post_id, author_id, created_at)
+        SELECT $2, p.id, p.user_id, p.created_at
+        FROM posts p
+        WHERE p.user_id = $1 AND NOT p.fanout_on_read
+        ORDER BY p.created_at DESC
+        LIMIT $3
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, authorId, followerId, timelineBackfillLimit)
+	return err
+}
+
+// pruneTimeline removes the author's posts from the follower's timeline.
+func (s *FollowersStore) pruneTimeline(ctx context.Context, tx *sql.Tx, authorId int64, followerId int64) error {
+	query := `
+        DELETE FROM timelines t
+        WHERE t.user_id = $2 AND t.author_id = $1
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, authorId, followerId)
 	return err
 }
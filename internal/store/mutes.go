@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type MuteStore struct {
+	db *sql.DB
+}
+
+// Mute leaves the user's posts and mentions out of the muter's feeds, without
+// them knowing. Muting again has no effect.
+func (s *MuteStore) Mute(ctx context.Context, userId int64, mutedId int64) error {
+	query := `
+        INSERT INTO user_mutes (user_id, muted_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, mutedId)
+	return err
+}
+
+func (s *MuteStore) Unmute(ctx context.Context, userId int64, mutedId int64) error {
+	query := `DELETE FROM user_mutes WHERE user_id = $1 AND muted_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, mutedId)
+	return err
+}
@@ -1,20 +1,30 @@
 package store
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type PaginationFeedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
+	Cursor string   `json:"cursor" validate:"max=255"`
+	Sort   string   `json:"sort" validate:"oneof=asc desc top hot"`
 	Tags   []string `json:"tags" validate:"max=5"`
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+	// Ranker overrides the default ranker for the top and hot sorts
+	Ranker FeedRanker `json:"-"`
 }
 
 const limitQsKey string = "limit"
@@ -24,6 +34,12 @@ const tagsQsKey string = "tags"
 const searchQsKey string = "search"
 const sinceQsKey string = "since"
 const untilQsKey string = "until"
+const depthQsKey string = "depth"
+const cursorQsKey string = "cursor"
+const commentsLimitQsKey string = "comments_limit"
+const queryQsKey string = "q"
+const typeQsKey string = "type"
+const windowQsKey string = "window"
 
 func (fq PaginationFeedQuery) Parse(r *http.Request) (PaginationFeedQuery, error) {
 	qs := r.URL.Query()
@@ -48,6 +64,11 @@ func (fq PaginationFeedQuery) Parse(r *http.Request) (PaginationFeedQuery, error
 		fq.Offset = o
 	}
 
+	cursor := qs.Get(cursorQsKey)
+	if cursor != "" {
+		fq.Cursor = cursor
+	}
+
 	sort := qs.Get(sortQsKey)
 	if sort != "" {
 		fq.Sort = sort
@@ -84,3 +105,337 @@ func parseTime(s string) string {
 
 	return t.Format(time.DateTime)
 }
+
+type PaginationCommentsQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor" validate:"max=255"`
+	Sort   string `json:"sort" validate:"oneof=asc desc"`
+	Depth  int    `json:"depth" validate:"gte=0"`
+}
+
+func (cq PaginationCommentsQuery) Parse(r *http.Request) (PaginationCommentsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get(limitQsKey)
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Limit = l
+	}
+
+	cursor := qs.Get(cursorQsKey)
+	if cursor != "" {
+		cq.Cursor = cursor
+	}
+
+	sort := qs.Get(sortQsKey)
+	if sort != "" {
+		cq.Sort = sort
+	}
+
+	depth := qs.Get(depthQsKey)
+	if depth != "" {
+		d, err := strconv.Atoi(depth)
+		if err != nil {
+			return cq, err
+		}
+
+		cq.Depth = d
+	}
+
+	return cq, nil
+}
+
+// PaginationCursorQuery pages through a list that is only walked with
+// cursors, in a fixed order.
+type PaginationCursorQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor" validate:"max=255"`
+}
+
+func (pq PaginationCursorQuery) Parse(r *http.Request) (PaginationCursorQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get(limitQsKey)
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return pq, err
+		}
+
+		pq.Limit = l
+	}
+
+	cursor := qs.Get(cursorQsKey)
+	if cursor != "" {
+		pq.Cursor = cursor
+	}
+
+	return pq, nil
+}
+
+type TrendingTagsQuery struct {
+	Window string `json:"window" validate:"oneof=1h 24h 7d"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+}
+
+func (tq TrendingTagsQuery) Parse(r *http.Request) (TrendingTagsQuery, error) {
+	qs := r.URL.Query()
+
+	window := qs.Get(windowQsKey)
+	if window != "" {
+		tq.Window = window
+	}
+
+	limit := qs.Get(limitQsKey)
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return tq, err
+		}
+
+		tq.Limit = l
+	}
+
+	return tq, nil
+}
+
+type SuggestionsQuery struct {
+	Limit int `json:"limit" validate:"gte=1,lte=50"`
+}
+
+func (sq SuggestionsQuery) Parse(r *http.Request) (SuggestionsQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get(limitQsKey)
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, err
+		}
+
+		sq.Limit = l
+	}
+
+	return sq, nil
+}
+
+type SearchQuery struct {
+	Query  string `json:"q" validate:"required,max=100"`
+	Type   string `json:"type" validate:"oneof=posts comments users"`
+	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
+	Cursor string `json:"cursor" validate:"max=255"`
+}
+
+func (sq SearchQuery) Parse(r *http.Request) (SearchQuery, error) {
+	qs := r.URL.Query()
+
+	query := qs.Get(queryQsKey)
+	if query != "" {
+		sq.Query = query
+	}
+
+	t := qs.Get(typeQsKey)
+	if t != "" {
+		sq.Type = t
+	}
+
+	limit := qs.Get(limitQsKey)
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, err
+		}
+
+		sq.Limit = l
+	}
+
+	cursor := qs.Get(cursorQsKey)
+	if cursor != "" {
+		sq.Cursor = cursor
+	}
+
+	return sq, nil
+}
+
+// PageCursors point at the pages either side of the one returned. Either is
+// empty when there is nothing more in that direction.
+type PageCursors struct {
+	Next string `json:"next_cursor"`
+	Prev string `json:"prev_cursor"`
+}
+
+type PostQuery struct {
+	CommentsLimit int `json:"comments_limit" validate:"gte=0,lte=50"`
+}
+
+func (pq PostQuery) Parse(r *http.Request) (PostQuery, error) {
+	qs := r.URL.Query()
+
+	commentsLimit := qs.Get(commentsLimitQsKey)
+	if commentsLimit != "" {
+		l, err := strconv.Atoi(commentsLimit)
+		if err != nil {
+			return pq, err
+		}
+
+		pq.CommentsLimit = l
+	}
+
+	return pq, nil
+}
+
+const (
+	cursorByCreatedAt = "created_at"
+	cursorByPosition  = "position"
+	cursorByRank      = "rank"
+)
+
+// cursor marks a position in a list ordered by (Key, id), where Kind names
+// what the key is. Prev is set when the page wanted is the one before the
+// position rather than after it.
+type cursor struct {
+	Kind string
+	Key  string
+	ID   int64
+	Prev bool
+}
+
+// cursorCodec turns cursors into opaque strings for clients. Each one is
+// signed so that it can't be forged to jump to arbitrary positions.
+type cursorCodec struct {
+	secret []byte
+}
+
+func (cc cursorCodec) encode(c cursor) string {
+	direction := "next"
+	if c.Prev {
+		direction = "prev"
+	}
+
+	payload := strings.Join([]string{c.Kind, c.Key, strconv.FormatInt(c.ID, 10), direction}, ",")
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(cc.sign(payload))
+}
+
+// decode verifies s and returns the cursor it holds, which must be of the
+// given kind.
+func (cc cursorCodec) decode(s string, kind string) (cursor, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(s, ".")
+	if !ok {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	if !hmac.Equal(signature, cc.sign(string(payload))) {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(payload), ",")
+	if len(parts) != 4 || parts[0] != kind {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return cursor{Kind: parts[0], Key: parts[1], ID: id, Prev: parts[3] == "prev"}, nil
+}
+
+func (cc cursorCodec) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, cc.secret)
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
+
+// keysetCondition builds the WHERE clause that continues a list sorted by
+// (created_at, id) in sort order from c, using the next two placeholders.
+func keysetCondition(alias string, sort string, c cursor, argPosition int) string {
+	return keysetConditionOn(alias+".created_at", alias+".id", sort, c, argPosition)
+}
+
+// keysetConditionOn is keysetCondition for lists sorted by other columns,
+// where createdAt is a timestamp and id tells rows with the same one apart.
+func keysetConditionOn(createdAt string, id string, sort string, c cursor, argPosition int) string {
+	op := "<"
+	if (sort == "asc") != c.Prev {
+		op = ">"
+	}
+
+	return ` AND (` + createdAt + `, ` + id + `) ` + op + ` ($` + strconv.Itoa(argPosition) + `::timestamptz, $` + strconv.Itoa(argPosition+1) + `)`
+}
+
+func newCreatedAtCursor(createdAt string, id int64, prev bool) cursor {
+	return cursor{Kind: cursorByCreatedAt, Key: createdAt, ID: id, Prev: prev}
+}
+
+// newPositionCursor points at an offset into a list that is recomputed on
+// every request, where there is no stable key to continue from.
+func newPositionCursor(offset int, prev bool) cursor {
+	return cursor{Kind: cursorByPosition, Key: strconv.Itoa(offset), Prev: prev}
+}
+
+// keysetPage turns the rows read for a page of a keyset-paginated list into
+// the page and cursors to the pages either side. Pages hold up to limit rows,
+// and the extra row read beyond that only tells whether there is more in the
+// direction read. A previous page is read backwards from its cursor, so with
+// prev the rows are flipped back into order. started says whether the page
+// begins past the start of the list, and key returns the timestamp and ID a
+// row is ordered by.
+func keysetPage[T any](cc cursorCodec, rows []T, limit int, prev bool, started bool, key func(T) (string, int64)) ([]T, PageCursors) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	if prev {
+		slices.Reverse(rows)
+	}
+
+	var cursors PageCursors
+	if len(rows) == 0 {
+		return rows, cursors
+	}
+
+	hasNext := hasMore
+	hasPrev := started
+	if prev {
+		hasNext = true
+		hasPrev = hasMore
+	}
+
+	if hasNext {
+		createdAt, id := key(rows[len(rows)-1])
+		cursors.Next = cc.encode(newCreatedAtCursor(createdAt, id, false))
+	}
+	if hasPrev {
+		createdAt, id := key(rows[0])
+		cursors.Prev = cc.encode(newCreatedAtCursor(createdAt, id, true))
+	}
+
+	return rows, cursors
+}
+
+// reverseSort flips a sort direction, which is how a previous page is read.
+func reverseSort(sort string) string {
+	if sort == "asc" {
+		return "desc"
+	}
+
+	return "asc"
+}
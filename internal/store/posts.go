@@ -1,69 +1,227 @@
 package store
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	Version   int       `json:"version"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID                 int64          `json:"id"`
+	Content            string         `json:"content"`
+	Title              string         `json:"title"`
+	UserID             int64          `json:"user_id"`
+	Tags               []string       `json:"tags"`
+	ExplicitTags       []string       `json:"-"`
+	Mentions           []string       `json:"mentions,omitempty"`
+	Version            int            `json:"version"`
+	CreatedAt          string         `json:"created_at"`
+	UpdatedAt          string         `json:"updated_at"`
+	Comments           []Comment      `json:"comments"`
+	CommentsNextCursor string         `json:"comments_next_cursor,omitempty"`
+	ReactionCounts     ReactionCounts `json:"reaction_counts"`
+	RepostOfID         *int64         `json:"repost_of_id,omitempty"`
+	QuoteOfID          *int64         `json:"quote_of_id,omitempty"`
+	RepostCount        int            `json:"repost_count"`
+	MyReactions        []string       `json:"my_reactions,omitempty"`
+	User               User           `json:"user"`
 }
 
 type PostWithMetadata struct {
 	Post
-	CommentCount int `json:"comments_count"`
+	CommentCount int         `json:"comments_count"`
+	RepostedBy   *RepostedBy `json:"reposted_by,omitempty"`
+	// Source says why the post is in the viewer's feed: it is their own
+	// ("self"), by someone they follow ("following") or carries a tag they
+	// follow ("tag"). It is empty for posts that are there for neither.
+	Source string `json:"source,omitempty"`
+
+	// The post that put this one in the feed, which is a repost of it when
+	// RepostedBy is set. Pages continue from its position.
+	feedId        int64
+	feedCreatedAt string
+}
+
+// RepostedBy describes the repost that brought a post into the feed.
+type RepostedBy struct {
+	PostID    int64  `json:"post_id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
 }
 
 type PostStore struct {
-	db *sql.DB
+	db      *sql.DB
+	cursors cursorCodec
 }
 
+// Create saves the post. Reposts and quotes always point at an original post,
+// so sharing a repost shares what it reposted.
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		if post.RepostOfID != nil {
+			if post.RepostOfID, err = s.getOriginalID(ctx, tx, *post.RepostOfID, post.UserID); err != nil {
+				return err
+			}
+		}
+		if post.QuoteOfID != nil {
+			if post.QuoteOfID, err = s.getOriginalID(ctx, tx, *post.QuoteOfID, post.UserID); err != nil {
+				return err
+			}
+		}
+
+		if err := s.create(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if err := s.createMentions(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if err := s.updatePostsCount(ctx, tx, post.UserID, 1); err != nil {
+			return err
+		}
+
+		if post.RepostOfID != nil {
+			return s.updateRepostCount(ctx, tx, *post.RepostOfID, 1)
+		}
+
+		return nil
+	})
+}
+
+func (s *PostStore) create(ctx context.Context, tx *sql.Tx, post *Post) error {
 	query := `
-        INSERT INTO posts (content, title, user_id, tags)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id, created_at, updated_at
+        INSERT INTO posts (content, title, user_id, tags, explicit_tags, repost_of_id, quote_of_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at, updated_at, reaction_counts
     `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		query,
 		post.Content,
 		post.Title,
 		post.UserID,
 		pq.Array(post.Tags),
+		pq.Array(post.ExplicitTags),
+		post.RepostOfID,
+		post.QuoteOfID,
 	).Scan(
 		&post.ID,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.ReactionCounts,
 	)
 
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+
 		return err
 	}
 
 	return nil
 }
 
+// createMentions records the active users among the post's mentions as
+// mentioned by it. Unknown usernames, and users who have blocked the author
+// or been blocked by them, are ignored.
+func (s *PostStore) createMentions(ctx context.Context, tx *sql.Tx, post *Post) error {
+	if len(post.Mentions) == 0 {
+		return nil
+	}
+
+	query := `
+        INSERT INTO post_mentions (post_id, user_id)
+        SELECT $1, u.id
+        FROM users u
+        WHERE u.username = ANY($2) AND u.is_active AND NOT ` + blockedBetween("u.id", "$3") + `
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, post.ID, pq.Array(post.Mentions), post.UserID)
+	return err
+}
+
+func (s *PostStore) deleteMentions(ctx context.Context, tx *sql.Tx, postId int64) error {
+	query := `DELETE FROM post_mentions WHERE post_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, postId)
+	return err
+}
+
+// getOriginalID returns the ID of the post that id reposts, or id itself when
+// it is not a repost. Posts the viewer can't see are not found.
+func (s *PostStore) getOriginalID(ctx context.Context, tx *sql.Tx, id int64, viewerId int64) (*int64, error) {
+	query := `
+        SELECT COALESCE(p.repost_of_id, p.id)
+        FROM posts p
+        JOIN posts d ON d.id = COALESCE(p.repost_of_id, p.id)
+        JOIN users u ON u.id = d.user_id
+        WHERE p.id = $1 AND ` + visibleTo("u", "$2") + `
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var originalId int64
+	err := tx.QueryRowContext(ctx, query, id, viewerId).Scan(&originalId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &originalId, nil
+}
+
+// updatePostsCount moves the user's posts count by delta.
+func (s *PostStore) updatePostsCount(ctx context.Context, tx *sql.Tx, userId int64, delta int) error {
+	query := `UPDATE users SET posts_count = posts_count + $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId, delta)
+	return err
+}
+
+func (s *PostStore) updateRepostCount(ctx context.Context, tx *sql.Tx, id int64, delta int) error {
+	query := `UPDATE posts SET repost_count = repost_count + $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id, delta)
+	return err
+}
+
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	query := `
-        SELECT id, user_id, content, title, tags, created_at, updated_at, version
+        SELECT id, user_id, content, title, tags, explicit_tags, created_at, updated_at, version, reaction_counts,
+            repost_of_id, quote_of_id, repost_count
         FROM posts 
         WHERE id = $1
     `
@@ -83,9 +241,14 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		&post.Content,
 		&post.Title,
 		pq.Array(&post.Tags),
+		pq.Array(&post.ExplicitTags),
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		&post.Version,
+		&post.ReactionCounts,
+		&post.RepostOfID,
+		&post.QuoteOfID,
+		&post.RepostCount,
 	)
 	if err != nil {
 		switch {
@@ -99,41 +262,394 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	return &post, nil
 }
 
-func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq PaginationFeedQuery) ([]PostWithMetadata, error) {
-	args := []interface{}{userId, fq.Search, pq.Array(fq.Tags), fq.Limit, fq.Offset}
+// GetUserFeed returns a page of posts written by the user, by the users they
+// follow or tagged with tags they follow. Most come from the user's timeline,
+// and the rest are posts not yet fanned out, by authors too widely followed to
+// fan out at all, or matched by tag. Posts by users they have muted, and
+// reposts of them, are left out.
+func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq PaginationFeedQuery) ([]PostWithMetadata, PageCursors, error) {
+	unmuted := `NOT ` + mutedBy("$1", "p.user_id") + ` AND NOT ` + mutedBy("$1", "d.user_id")
+
+	sources := []feedSource{
+		{
+			from:      `timelines t JOIN posts p ON p.id = t.post_id`,
+			where:     `t.user_id = $1 AND ` + unmuted,
+			createdAt: "t.created_at",
+			id:        "t.post_id",
+		},
+		postsSource(`p.fanout_on_read AND (
+                p.user_id = $1 OR
+                p.user_id IN (SELECT rf.user_id FROM followers rf WHERE rf.follower_id = $1)
+            ) AND ` + unmuted),
+		postsSource(`p.tags && ARRAY(SELECT tf.tag FROM tag_follows tf WHERE tf.user_id = $1) AND ` + unmuted),
+	}
+
+	return s.getFeed(ctx, userId, sources, fq)
+}
+
+// GetMentionsFeed returns a page of posts that mention the user, other than
+// those by users they have muted.
+func (s *PostStore) GetMentionsFeed(ctx context.Context, userId int64, fq PaginationFeedQuery) ([]PostWithMetadata, PageCursors, error) {
+	sources := []feedSource{
+		{
+			from:      `post_mentions pm JOIN posts p ON p.id = pm.post_id`,
+			where:     `pm.user_id = $1 AND NOT ` + mutedBy("$1", "p.user_id"),
+			createdAt: "p.created_at",
+			id:        "p.id",
+		},
+	}
+
+	return s.getFeed(ctx, userId, sources, fq)
+}
+
+// GetExploreFeed returns a page of posts from every user.
+func (s *PostStore) GetExploreFeed(ctx context.Context, viewerId int64, fq PaginationFeedQuery) ([]PostWithMetadata, PageCursors, error) {
+	return s.getFeed(ctx, viewerId, []feedSource{postsSource("")}, fq)
+}
+
+// getFeed returns a page of posts from the sources matching the feed query.
+// The page is picked from the sources alone, and only its posts are joined
+// with what is displayed for them. A cursor from a previous page takes
+// precedence over the offset, which is kept for older clients.
+func (s *PostStore) getFeed(ctx context.Context, viewerId int64, sources []feedSource, fq PaginationFeedQuery) ([]PostWithMetadata, PageCursors, error) {
+	if ranker, ok := feedRanker(fq); ok {
+		return s.getRankedFeed(ctx, viewerId, sources, fq, ranker)
+	}
+
+	var c *cursor
+	if fq.Cursor != "" {
+		decoded, err := s.cursors.decode(fq.Cursor, cursorByCreatedAt)
+		if err != nil {
+			return nil, PageCursors{}, err
+		}
+
+		c = &decoded
+		fq.Offset = 0
+	}
+
+	sort := fq.Sort
+	prev := c != nil && c.Prev
+	if prev {
+		sort = reverseSort(fq.Sort)
+	}
+
+	// Every source has to reach past the offset, since any of them may fill it
+	candidates, args := feedCandidates(viewerId, sources, fq, c, sort, fq.Limit+1+fq.Offset, false)
 
-	// Base Query
 	query := `
-        SELECT
-            p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
-            u.username,
-            COUNT(c.id) as comments_count
-        FROM posts p
-        LEFT JOIN comments c ON c.post_id = p.id
-        LEFT JOIN users u ON u.id = p.user_id
-        LEFT JOIN followers f ON f.follower_id = $1 AND f.user_id = p.user_id
-        WHERE 
-            f.follower_id = $1 AND
-            (p.title ILIKE '%' || $2 || '%' OR p.content ILIKE '%' || $2 || '%') AND
-            (p.tags @> $3 OR $3 = '{}') 
+        SELECT` + feedColumns + `,` + feedSourceColumn + `
+        FROM (
+            SELECT fc.id
+            FROM (` + candidates + `) fc
+            ORDER BY fc.created_at ` + sort + `, fc.id ` + sort + `
+            LIMIT $` + strconv.Itoa(len(args)+1) + `
+            OFFSET $` + strconv.Itoa(len(args)+2) + `
+        ) page
+        JOIN posts p ON p.id = page.id` + feedJoins + `
+        GROUP BY ` + feedGroupBy + `
+        ORDER BY p.created_at ` + sort + `, p.id ` + sort + `;
     `
+	args = append(args, fq.Limit+1, fq.Offset)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
 
-	// Dates
+	defer rows.Close()
+
+	var feed []PostWithMetadata
+	for rows.Next() {
+		var (
+			post   PostWithMetadata
+			source sql.NullString
+		)
+
+		if err := scanFeedRow(rows, &post, &source); err != nil {
+			return nil, PageCursors{}, err
+		}
+
+		post.Source = source.String
+
+		feed = append(feed, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	// Cursors come from the rows read, including any dropped as duplicates
+	feed, cursors := keysetPage(s.cursors, feed, fq.Limit, prev, fq.Offset > 0 || fq.Cursor != "", func(post PostWithMetadata) (string, int64) {
+		return post.feedCreatedAt, post.feedId
+	})
+
+	return dedupeFeed(feed), cursors, nil
+}
+
+// feedColumns selects a feed row, where p is the post in the feed and d is
+// the post displayed for it, which is the original when p is a repost. The
+// viewer is $1. Rows scan with scanFeedRow.
+const feedColumns = `
+            d.id, d.user_id, d.title, d.content, d.created_at, d.version, d.tags,
+            d.reaction_counts, d.repost_of_id, d.quote_of_id, d.repost_count,
+            ARRAY(
+                SELECT pr.kind FROM post_reactions pr WHERE pr.post_id = d.id AND pr.user_id = $1 ORDER BY pr.kind
+            ) AS my_reactions,
+            u.username,
+            COUNT(c.id) as comments_count,
+            p.id AS feed_id, p.created_at AS feed_created_at,
+            p.repost_of_id IS NOT NULL AS is_repost, p.user_id AS reposter_id, ru.username AS reposter_username
+`
+
+// feedJoins joins what feedColumns needs onto the feed post p.
+const feedJoins = `
+        JOIN posts d ON d.id = COALESCE(p.repost_of_id, p.id)
+        LEFT JOIN comments c ON c.post_id = d.id
+        LEFT JOIN users u ON u.id = d.user_id
+        LEFT JOIN users ru ON ru.id = p.user_id
+`
+
+const feedGroupBy = `p.id, d.id, u.username, ru.username`
+
+// feedSourceColumn says why a feed row is in the viewer's feed, and is
+// selected after feedColumns.
+const feedSourceColumn = `
+            CASE
+                WHEN p.user_id = $1 THEN 'self'
+                WHEN EXISTS (
+                    SELECT 1 FROM followers sf WHERE sf.follower_id = $1 AND sf.user_id = p.user_id
+                ) THEN 'following'
+                WHEN p.tags && ARRAY(SELECT stf.tag FROM tag_follows stf WHERE stf.user_id = $1) THEN 'tag'
+            END AS source
+`
+
+// feedSource is one place a feed's posts come from. from yields the post in
+// the feed as p, narrowed down by where, which can refer to the viewer as $1
+// and to the displayed post as d. createdAt and id are the columns the source
+// is walked in order by, which an index should cover.
+type feedSource struct {
+	from      string
+	where     string
+	createdAt string
+	id        string
+}
+
+// postsSource is a feed source reading posts directly.
+func postsSource(where string) feedSource {
+	return feedSource{from: "posts p", where: where, createdAt: "p.created_at", id: "p.id"}
+}
+
+// feedEngagement is how much engagement the displayed post d has had.
+const feedEngagement = `(
+                (SELECT COALESCE(SUM(er.value::int), 0) FROM jsonb_each_text(d.reaction_counts) er) +
+                (SELECT COUNT(*) FROM comments ec WHERE ec.post_id = d.id)
+            )`
+
+// feedCandidates builds a query for the IDs and creation times of up to limit
+// posts from each source, in sort order from the cursor on when there is one.
+// With engaged, each source also brings up to limit of its most engaged
+// posts. Each source is walked on its own so that it can stop as soon as it
+// has enough, and duplicates across them are merged. Search and tags match
+// the displayed post, while dates apply to the post in the feed. Posts of
+// users the viewer may not see are left out.
+func feedCandidates(viewerId int64, sources []feedSource, fq PaginationFeedQuery, c *cursor, sort string, limit int, engaged bool) (string, []interface{}) {
+	args := []interface{}{viewerId, fq.Search, pq.Array(fq.Tags)}
+
+	var sincePos, untilPos, keysetPos int
 	if fq.Since != "" {
-		query += ` AND p.created_at > $` + strconv.Itoa(len(args)+1) + `::timestamp`
 		args = append(args, fq.Since)
+		sincePos = len(args)
 	}
 	if fq.Until != "" {
-		query += ` AND p.created_at < $` + strconv.Itoa(len(args)+1) + `::timestamp`
 		args = append(args, fq.Until)
+		untilPos = len(args)
+	}
+	if c != nil {
+		args = append(args, c.Key, c.ID)
+		keysetPos = len(args) - 1
+	}
+	args = append(args, limit)
+	limitPos := len(args)
+
+	branches := make([]string, 0, len(sources))
+	for _, src := range sources {
+		branch := `(
+            SELECT p.id, p.created_at
+            FROM ` + src.from + `
+            JOIN posts d ON d.id = COALESCE(p.repost_of_id, p.id)
+            JOIN users u ON u.id = d.user_id
+            JOIN users ru ON ru.id = p.user_id
+            WHERE
+                ($2 = '' OR d.search_vector @@ websearch_to_tsquery('english', $2)) AND
+                (d.tags @> $3 OR $3 = '{}') AND
+                ` + visibleTo("u", "$1") + ` AND
+                ` + visibleTo("ru", "$1")
+
+		if src.where != "" {
+			branch += ` AND ` + src.where
+		}
+
+		// Dates
+		if sincePos > 0 {
+			branch += ` AND ` + src.createdAt + ` > $` + strconv.Itoa(sincePos) + `::timestamp`
+		}
+		if untilPos > 0 {
+			branch += ` AND ` + src.createdAt + ` < $` + strconv.Itoa(untilPos) + `::timestamp`
+		}
+
+		// Keyset
+		if c != nil {
+			branch += keysetConditionOn(src.createdAt, src.id, fq.Sort, *c, keysetPos)
+		}
+
+		branches = append(branches, branch+`
+            ORDER BY `+src.createdAt+` `+sort+`, `+src.id+` `+sort+`
+            LIMIT $`+strconv.Itoa(limitPos)+`
+        )`)
+
+		if engaged {
+			branches = append(branches, branch+`
+            ORDER BY `+feedEngagement+` DESC, `+src.createdAt+` DESC, `+src.id+` DESC
+            LIMIT $`+strconv.Itoa(limitPos)+`
+        )`)
+		}
+	}
+
+	return strings.Join(branches, " UNION "), args
+}
+
+// scanFeedRow scans a row selected with feedColumns into post, followed by
+// any extra columns into extra.
+func scanFeedRow(rows *sql.Rows, post *PostWithMetadata, extra ...interface{}) error {
+	var (
+		isRepost   bool
+		repostedBy RepostedBy
+	)
+
+	dest := []interface{}{
+		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Content,
+		&post.CreatedAt,
+		&post.Version,
+		pq.Array(&post.Tags),
+		&post.ReactionCounts,
+		&post.RepostOfID,
+		&post.QuoteOfID,
+		&post.RepostCount,
+		pq.Array(&post.MyReactions),
+		&post.User.Username,
+		&post.CommentCount,
+		&post.feedId,
+		&post.feedCreatedAt,
+		&isRepost,
+		&repostedBy.UserID,
+		&repostedBy.Username,
+	}
+
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if isRepost {
+		repostedBy.PostID = post.feedId
+		repostedBy.CreatedAt = post.feedCreatedAt
+		post.RepostedBy = &repostedBy
 	}
 
-	// Sorting, Pagination
-	query += `
-        GROUP BY p.id, u.username
-        ORDER BY p.created_at ` + fq.Sort + `
-        LIMIT $4
-        OFFSET $5;
+	return nil
+}
+
+// dedupeFeed keeps only the first appearance of each post, so that a post
+// reposted by several people shows up once.
+func dedupeFeed(feed []PostWithMetadata) []PostWithMetadata {
+	seen := make(map[int64]bool, len(feed))
+
+	return slices.DeleteFunc(feed, func(post PostWithMetadata) bool {
+		if seen[post.ID] {
+			return true
+		}
+
+		seen[post.ID] = true
+		return false
+	})
+}
+
+// rankedFeedCandidates caps how many of its newest and how many of its most
+// engaged posts each source brings to a ranked feed. Pages past the ranked
+// candidates come back empty.
+const rankedFeedCandidates = 500
+
+// feedRanker returns the ranker for a ranked sort, preferring one set on the
+// query over the default for that sort.
+func feedRanker(fq PaginationFeedQuery) (FeedRanker, bool) {
+	if fq.Ranker != nil {
+		return fq.Ranker, true
+	}
+
+	ranker, ok := FeedRankers[fq.Sort]
+
+	return ranker, ok
+}
+
+// getRankedFeed scores the newest and the most engaged posts matching the
+// feed query within the sort's window with ranker, and returns a page of
+// them, best first. Scores decay as posts age, so a position in the ranking
+// is only meaningful for a short while and cursors hold the offset of the
+// page rather than a keyset.
+func (s *PostStore) getRankedFeed(ctx context.Context, viewerId int64, sources []feedSource, fq PaginationFeedQuery, ranker FeedRanker) ([]PostWithMetadata, PageCursors, error) {
+	if fq.Cursor != "" {
+		c, err := s.cursors.decode(fq.Cursor, cursorByPosition)
+		if err != nil {
+			return nil, PageCursors{}, err
+		}
+
+		offset, err := strconv.Atoi(c.Key)
+		if err != nil || offset < 0 {
+			return nil, PageCursors{}, ErrInvalidCursor
+		}
+
+		fq.Offset = offset
+	}
+
+	// Posts older than the window are never ranked, however engaged
+	windowStart := time.Now().UTC().Add(-rankedFeedWindow(fq.Sort)).Format(time.DateTime)
+	if fq.Since < windowStart {
+		fq.Since = windowStart
+	}
+
+	// The merged candidates are capped like each source is, by the last argument
+	candidates, args := feedCandidates(viewerId, sources, fq, nil, "desc", rankedFeedCandidates, true)
+
+	query := `
+        SELECT
+            feed.*,
+            (
+                SELECT COUNT(*)
+                FROM comments vc
+                JOIN posts vp ON vp.id = vc.post_id
+                WHERE vc.user_id = $1 AND vp.user_id = feed.user_id
+            ) AS interactions,
+            EXISTS (
+                SELECT 1 FROM followers vf WHERE vf.follower_id = $1 AND vf.user_id = feed.user_id
+            ) AS following,
+            EXTRACT(EPOCH FROM now() - feed.created_at) AS age
+        FROM (
+            SELECT` + feedColumns + `,` + feedSourceColumn + `
+            FROM (
+                SELECT fc.id
+                FROM (` + candidates + `) fc
+                ORDER BY fc.created_at DESC, fc.id DESC
+                LIMIT $` + strconv.Itoa(len(args)) + `
+            ) page
+            JOIN posts p ON p.id = page.id` + feedJoins + `
+            GROUP BY ` + feedGroupBy + `
+            ORDER BY p.created_at DESC, p.id DESC
+        ) feed;
     `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -141,63 +657,154 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userId int64, fq Pagination
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, PageCursors{}, err
 	}
 
 	defer rows.Close()
 
-	var feed []PostWithMetadata
+	type rankedPost struct {
+		post  PostWithMetadata
+		score float64
+	}
+
+	var ranked []rankedPost
 	for rows.Next() {
-		var post PostWithMetadata
-		err := rows.Scan(
-			&post.ID,
-			&post.UserID,
-			&post.Title,
-			&post.Content,
-			&post.CreatedAt,
-			&post.Version,
-			pq.Array(&post.Tags),
-			&post.User.Username,
-			&post.CommentCount,
+		var (
+			rp      rankedPost
+			source  sql.NullString
+			signals FeedSignals
+			age     float64
 		)
 
-		if err != nil {
-			return nil, err
+		if err := scanFeedRow(rows, &rp.post, &source, &signals.Interactions, &signals.Following, &age); err != nil {
+			return nil, PageCursors{}, err
 		}
 
-		feed = append(feed, post)
+		rp.post.Source = source.String
+
+		signals.CommentCount = rp.post.CommentCount
+		signals.Reactions = rp.post.ReactionCounts.Total()
+		signals.Age = time.Duration(age * float64(time.Second))
+		rp.score = ranker.Score(signals)
+
+		ranked = append(ranked, rp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, PageCursors{}, err
 	}
 
-	return feed, nil
+	// Candidates arrive newest first, so a stable sort breaks ties by recency
+	slices.SortStableFunc(ranked, func(a, b rankedPost) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	all := make([]PostWithMetadata, 0, len(ranked))
+	for _, rp := range ranked {
+		all = append(all, rp.post)
+	}
+
+	// The whole ranking is deduplicated, so offsets stay valid across pages
+	all = dedupeFeed(all)
+
+	start := min(fq.Offset, len(all))
+	end := min(start+fq.Limit, len(all))
+	feed := all[start:end]
+
+	var cursors PageCursors
+	if end < len(all) {
+		cursors.Next = s.cursors.encode(newPositionCursor(end, false))
+	}
+	if start > 0 {
+		cursors.Prev = s.cursors.encode(newPositionCursor(max(start-fq.Limit, 0), true))
+	}
+
+	return feed, cursors, nil
 }
 
+// DeleteByID deletes the post, taking it off its author's posts count and the
+// repost count of the post it reposts. Reposts of it go with it, and come off
+// their authors' posts counts too.
 func (s *PostStore) DeleteByID(ctx context.Context, id int64) error {
-	query := "DELETE FROM posts p WHERE p.id = $1"
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.uncountReposts(ctx, tx, id); err != nil {
+			return err
+		}
+
+		userId, repostOfId, err := s.delete(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.updatePostsCount(ctx, tx, userId, -1); err != nil {
+			return err
+		}
+
+		if repostOfId != nil {
+			return s.updateRepostCount(ctx, tx, *repostOfId, -1)
+		}
+
+		return nil
+	})
+}
+
+func (s *PostStore) uncountReposts(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `
+        UPDATE users u
+        SET posts_count = u.posts_count - 1
+        FROM posts p
+        WHERE p.repost_of_id = $1 AND p.user_id = u.id
+    `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
-	}
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+// delete deletes the post and returns its author and the post it reposts.
+func (s *PostStore) delete(ctx context.Context, tx *sql.Tx, id int64) (int64, *int64, error) {
+	query := "DELETE FROM posts p WHERE p.id = $1 RETURNING p.user_id, p.repost_of_id"
 
-	if rows == 0 {
-		return ErrNotFound
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userId int64
+	var repostOfId *int64
+	err := tx.QueryRowContext(ctx, query, id).Scan(&userId, &repostOfId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, nil, ErrNotFound
+		default:
+			return 0, nil, err
+		}
 	}
 
-	return nil
+	return userId, repostOfId, nil
 }
 
+// Update saves the post's title, content and tags, and replaces its mentions
+// with the post's current ones.
 func (s *PostStore) Update(ctx context.Context, p *Post) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.update(ctx, tx, p); err != nil {
+			return err
+		}
+
+		if err := s.deleteMentions(ctx, tx, p.ID); err != nil {
+			return err
+		}
+
+		return s.createMentions(ctx, tx, p)
+	})
+}
+
+func (s *PostStore) update(ctx context.Context, tx *sql.Tx, p *Post) error {
 	query := `
         UPDATE posts p
-        SET title = $2, content = $3, version = p.version + 1
+        SET title = $2, content = $3, tags = $5, explicit_tags = $6, version = p.version + 1
         WHERE p.id = $1
         AND p.version = $4
         RETURNING p.version
@@ -206,13 +813,15 @@ func (s *PostStore) Update(ctx context.Context, p *Post) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		query,
 		p.ID,
 		p.Title,
 		p.Content,
 		p.Version,
+		pq.Array(p.Tags),
+		pq.Array(p.ExplicitTags),
 	).Scan(&p.Version)
 	if err != nil {
 		switch {
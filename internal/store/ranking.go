@@ -0,0 +1,68 @@
+package store
+
+import (
+	"math"
+	"time"
+)
+
+// FeedSignals are what is known about a post when ranking it for a viewer.
+type FeedSignals struct {
+	CommentCount int
+	Reactions    int
+	// Interactions counts the viewer's comments on the author's posts
+	Interactions int
+	Following    bool
+	Age          time.Duration
+}
+
+// FeedRanker scores posts for the ranked feed sorts, higher first. Scores only
+// need to be comparable with each other within one page request.
+type FeedRanker interface {
+	Score(s FeedSignals) float64
+}
+
+// GravityRanker divides a post's engagement by its age raised to Gravity, so
+// a higher gravity lets older posts sink faster. Affinity with the author
+// multiplies the engagement rather than adding to it, which keeps a quiet
+// post from a close friend above a quiet post from a stranger.
+type GravityRanker struct {
+	CommentWeight  float64
+	ReactionWeight float64
+	AffinityWeight float64
+	Gravity        float64
+}
+
+func (gr GravityRanker) Score(s FeedSignals) float64 {
+	engagement := 1 + gr.CommentWeight*float64(s.CommentCount) + gr.ReactionWeight*float64(s.Reactions)
+
+	affinity := float64(s.Interactions)
+	if s.Following {
+		affinity++
+	}
+
+	return engagement * (1 + gr.AffinityWeight*affinity) / math.Pow(s.Age.Hours()+2, gr.Gravity)
+}
+
+// rankedFeedWindows holds how far back each ranked sort looks for posts.
+// Sorts given a ranker of their own look back defaultRankedFeedWindow.
+var rankedFeedWindows = map[string]time.Duration{
+	"hot": 2 * 24 * time.Hour,
+	"top": 7 * 24 * time.Hour,
+}
+
+const defaultRankedFeedWindow = 7 * 24 * time.Hour
+
+func rankedFeedWindow(sort string) time.Duration {
+	if window, ok := rankedFeedWindows[sort]; ok {
+		return window
+	}
+
+	return defaultRankedFeedWindow
+}
+
+// FeedRankers holds the ranker used for each ranked sort unless the feed
+// query brings its own.
+var FeedRankers = map[string]FeedRanker{
+	"hot": GravityRanker{CommentWeight: 1, ReactionWeight: 0.5, AffinityWeight: 0.5, Gravity: 1.8},
+	"top": GravityRanker{CommentWeight: 1, ReactionWeight: 0.5, AffinityWeight: 0.5, Gravity: 0.5},
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// ReactionCounts holds how many times a post was reacted to with each kind.
+// It is kept on the post itself so that reading it costs nothing extra.
+type ReactionCounts map[string]int
+
+func (rc *ReactionCounts) Scan(src any) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("reaction counts: expected jsonb")
+	}
+
+	return json.Unmarshal(b, rc)
+}
+
+// Total is the number of reactions of every kind.
+func (rc ReactionCounts) Total() int {
+	total := 0
+	for _, count := range rc {
+		total += count
+	}
+
+	return total
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// Add reacts to the post on behalf of the user. Reacting twice with the same
+// kind is a no-op.
+func (s *ReactionStore) Add(ctx context.Context, postId int64, userId int64, kind string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		added, err := s.create(ctx, tx, postId, userId, kind)
+		if err != nil || !added {
+			return err
+		}
+
+		return s.updateCount(ctx, tx, postId, kind, 1)
+	})
+}
+
+// Remove takes back the user's reaction of the given kind, if there is one.
+func (s *ReactionStore) Remove(ctx context.Context, postId int64, userId int64, kind string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		removed, err := s.delete(ctx, tx, postId, userId, kind)
+		if err != nil || !removed {
+			return err
+		}
+
+		return s.updateCount(ctx, tx, postId, kind, -1)
+	})
+}
+
+// GetUserKinds returns the kinds the user reacted to the post with.
+func (s *ReactionStore) GetUserKinds(ctx context.Context, postId int64, userId int64) ([]string, error) {
+	query := `
+        SELECT kind
+        FROM post_reactions
+        WHERE post_id = $1 AND user_id = $2
+        ORDER BY created_at, kind
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	kinds := []string{}
+	for rows.Next() {
+		var kind string
+		if err := rows.Scan(&kind); err != nil {
+			return nil, err
+		}
+
+		kinds = append(kinds, kind)
+	}
+
+	return kinds, rows.Err()
+}
+
+func (s *ReactionStore) create(ctx context.Context, tx *sql.Tx, postId int64, userId int64, kind string) (bool, error) {
+	query := `
+        INSERT INTO post_reactions (post_id, user_id, kind)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, postId, userId, kind)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (s *ReactionStore) delete(ctx context.Context, tx *sql.Tx, postId int64, userId int64, kind string) (bool, error) {
+	query := `
+        DELETE FROM post_reactions
+        WHERE post_id = $1 AND user_id = $2 AND kind = $3
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, postId, userId, kind)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// updateCount moves the post's count for kind by delta, dropping the kind
+// once nobody is left reacting with it.
+func (s *ReactionStore) updateCount(ctx context.Context, tx *sql.Tx, postId int64, kind string, delta int) error {
+	query := `
+        UPDATE posts p
+        SET reaction_counts = CASE
+            WHEN COALESCE((p.reaction_counts ->> $2::text)::int, 0) + $3 > 0
+                THEN jsonb_set(p.reaction_counts, ARRAY[$2::text], to_jsonb(COALESCE((p.reaction_counts ->> $2::text)::int, 0) + $3))
+            ELSE p.reaction_counts - $2::text
+        END
+        WHERE p.id = $1
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, postId, kind, delta)
+	return err
+}
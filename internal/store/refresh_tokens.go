@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	Expiry    time.Time  `json:"expiry"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, userId int64, token string, exp time.Duration) error {
+	query := `
+        INSERT INTO refresh_tokens (token, user_id, expiry)
+        VALUES ($1, $2, $3)
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, hashToken(token), userId, time.Now().Add(exp))
+	return err
+}
+
+func (s *RefreshTokenStore) Revoke(ctx context.Context, token string) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = now()
+        WHERE token = $1 AND revoked_at IS NULL
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Rotate exchanges a refresh token for a new one in the same family. Presenting
+// a token that has already been rotated or revoked revokes the whole family.
+// It returns ErrNotFound if the user no longer exists and ErrUserInactive if
+// they are not active, leaving the token as it is.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token string, newToken string, exp time.Duration) (*RefreshToken, error) {
+	var rotated *RefreshToken
+	reused := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getByToken(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return s.revokeFamily(ctx, tx, current.FamilyID)
+		}
+
+		if current.Expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		if err := s.checkUser(ctx, tx, current.UserID); err != nil {
+			return err
+		}
+
+		if err := s.revoke(ctx, tx, current.ID); err != nil {
+			return err
+		}
+
+		rotated = &RefreshToken{
+			UserID:   current.UserID,
+			FamilyID: current.FamilyID,
+			Expiry:   time.Now().Add(exp),
+		}
+
+		return s.createInFamily(ctx, tx, rotated, newToken)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, ErrTokenReused
+	}
+
+	return rotated, nil
+}
+
+func (s *RefreshTokenStore) createInFamily(ctx context.Context, tx *sql.Tx, rt *RefreshToken, token string) error {
+	query := `
+        INSERT INTO refresh_tokens (token, user_id, family_id, expiry)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		hashToken(token),
+		rt.UserID,
+		rt.FamilyID,
+		rt.Expiry,
+	).Scan(
+		&rt.ID,
+		&rt.CreatedAt,
+	)
+}
+
+func (s *RefreshTokenStore) getByToken(ctx context.Context, tx *sql.Tx, token string) (*RefreshToken, error) {
+	query := `
+        SELECT id, user_id, family_id, expiry, revoked_at, created_at
+        FROM refresh_tokens
+        WHERE token = $1
+        FOR UPDATE
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rt := &RefreshToken{}
+
+	if err := tx.QueryRowContext(ctx, query, hashToken(token)).Scan(
+		&rt.ID,
+		&rt.UserID,
+		&rt.FamilyID,
+		&rt.Expiry,
+		&rt.RevokedAt,
+		&rt.CreatedAt,
+	); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return rt, nil
+}
+
+func (s *RefreshTokenStore) checkUser(ctx context.Context, tx *sql.Tx, userId int64) error {
+	query := `SELECT is_active FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var isActive bool
+	if err := tx.QueryRowContext(ctx, query, userId).Scan(&isActive); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	if !isActive {
+		return ErrUserInactive
+	}
+
+	return nil
+}
+
+func (s *RefreshTokenStore) revoke(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `UPDATE refresh_tokens SET revoked_at = now() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+func (s *RefreshTokenStore) revokeFamily(ctx context.Context, tx *sql.Tx, familyId string) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = now()
+        WHERE family_id = $1 AND revoked_at IS NULL
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, familyId)
+	return err
+}
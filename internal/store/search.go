@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+type SearchResult struct {
+	Type      string  `json:"type"`
+	ID        int64   `json:"id"`
+	Rank      float32 `json:"rank"`
+	Headline  string  `json:"headline"`
+	Title     string  `json:"title,omitempty"`
+	PostID    int64   `json:"post_id,omitempty"`
+	CreatedAt string  `json:"created_at"`
+	User      User    `json:"user"`
+}
+
+type SearchStore struct {
+	db      *sql.DB
+	cursors cursorCodec
+}
+
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5`
+
+// Each search query ranks the matching rows in a "ranked" CTE, from which a
+// page is taken and only then highlighted, since ts_headline is expensive.
+// Posts the viewer may not see, and the comments on them, are left out, as
+// is anything by users the viewer has blocked or been blocked by. They all take
+// the search terms as $1, the page size as $2 and the viewer as $3, and may
+// be followed by a keyset on (rank, id).
+var searchQueries = map[string]struct {
+	ranked string
+	page   string
+}{
+	"posts": {
+		ranked: `
+            SELECT p.id, ts_rank(p.search_vector, q.query) AS rank
+            FROM posts p
+            JOIN users pu ON pu.id = p.user_id, q
+            WHERE p.search_vector @@ q.query AND ` + visibleTo("pu", "$3") + `
+        `,
+		page: `
+            SELECT
+                r.id, r.rank, ts_headline('english', p.content, q.query, '` + headlineOptions + `'),
+                p.title, p.id, p.created_at, u.id, u.username
+            FROM page r
+            JOIN posts p ON p.id = r.id
+            JOIN users u ON u.id = p.user_id, q
+        `,
+	},
+	"comments": {
+		ranked: `
+            SELECT c.id, ts_rank(c.search_vector, q.query) AS rank
+            FROM comments c
+            JOIN posts cp ON cp.id = c.post_id
+            JOIN users cu ON cu.id = cp.user_id, q
+            WHERE c.search_vector @@ q.query AND c.deleted_at IS NULL
+            AND ` + visibleTo("cu", "$3") + ` AND NOT ` + blockedBetween("c.user_id", "$3") + `
+        `,
+		page: `
+            SELECT
+                r.id, r.rank, ts_headline('english', c.content, q.query, '` + headlineOptions + `'),
+                '', c.post_id, c.created_at, u.id, u.username
+            FROM page r
+            JOIN comments c ON c.id = r.id
+            JOIN users u ON u.id = c.user_id, q
+        `,
+	},
+	"users": {
+		ranked: `
+            SELECT u.id, ts_rank(u.search_vector, q.simple_query) AS rank
+            FROM users u, q
+            WHERE u.search_vector @@ q.simple_query AND u.is_active AND NOT ` + blockedBetween("u.id", "$3") + `
+        `,
+		page: `
+            SELECT
+                r.id, r.rank, ts_headline('simple', u.username, q.simple_query, '` + headlineOptions + `'),
+                '', 0, u.created_at, u.id, u.username
+            FROM page r
+            JOIN users u ON u.id = r.id, q
+        `,
+	},
+}
+
+// Search returns the posts, comments or users matching the search query,
+// most relevant first. The terms use websearch_to_tsquery syntax, so quoted
+// phrases, "or" and "-" exclusions are supported.
+func (s *SearchStore) Search(ctx context.Context, viewerId int64, sq SearchQuery) ([]SearchResult, PageCursors, error) {
+	sqlQueries, ok := searchQueries[sq.Type]
+	if !ok {
+		return nil, PageCursors{}, ErrNotFound
+	}
+
+	args := []interface{}{sq.Query, sq.Limit + 1, viewerId}
+
+	// Keyset
+	keyset := ""
+	if sq.Cursor != "" {
+		c, err := s.cursors.decode(sq.Cursor, cursorByRank)
+		if err != nil || c.Prev {
+			return nil, PageCursors{}, ErrInvalidCursor
+		}
+
+		keyset = `WHERE (r.rank, r.id) < ($` + strconv.Itoa(len(args)+1) + `::real, $` + strconv.Itoa(len(args)+2) + `)`
+		args = append(args, c.Key, c.ID)
+	}
+
+	query := `
+        WITH q AS (
+            SELECT websearch_to_tsquery('english', $1) AS query, websearch_to_tsquery('simple', $1) AS simple_query
+        ), ranked AS (` + sqlQueries.ranked + `), page AS (
+            SELECT r.id, r.rank
+            FROM ranked r
+            ` + keyset + `
+            ORDER BY r.rank DESC, r.id DESC
+            LIMIT $2
+        )` + sqlQueries.page + `
+        ORDER BY r.rank DESC, r.id DESC
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, PageCursors{}, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		result := SearchResult{Type: sq.Type}
+
+		err := rows.Scan(
+			&result.ID,
+			&result.Rank,
+			&result.Headline,
+			&result.Title,
+			&result.PostID,
+			&result.CreatedAt,
+			&result.User.ID,
+			&result.User.Username,
+		)
+		if err != nil {
+			return nil, PageCursors{}, err
+		}
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, PageCursors{}, err
+	}
+
+	var cursors PageCursors
+	if len(results) > sq.Limit {
+		results = results[:sq.Limit]
+
+		last := results[len(results)-1]
+		cursors.Next = s.cursors.encode(cursor{
+			Kind: cursorByRank,
+			Key:  strconv.FormatFloat(float64(last.Rank), 'g', -1, 32),
+			ID:   last.ID,
+		})
+	}
+
+	return results, cursors, nil
+}
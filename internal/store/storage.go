@@ -2,48 +2,127 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"time"
 )
 
 var (
+	ErrBlocked           = errors.New("one of the users has blocked the other")
 	ErrConflict          = errors.New("resource already exists")
+	ErrEditConflict      = errors.New("resource was modified by another request")
 	ErrNotFound          = errors.New("resource not found")
+	ErrTokenReused       = errors.New("token has already been used")
+	ErrUserInactive      = errors.New("user is not active")
 	QueryTimeoutDuration = time.Second * 5
 )
 
 type Storage struct {
+	Blocks interface {
+		Block(ctx context.Context, userId int64, blockedId int64) error
+		Unblock(ctx context.Context, userId int64, blockedId int64) error
+	}
+	Bookmarks interface {
+		Add(ctx context.Context, postId int64, userId int64) error
+		List(ctx context.Context, userId int64, bq PaginationCursorQuery) ([]Bookmark, PageCursors, error)
+		Remove(ctx context.Context, postId int64, userId int64) error
+	}
 	Comments interface {
 		Create(ctx context.Context, c *Comment) error
-		GetByPostID(ctx context.Context, postId int64) ([]Comment, error)
+		DeleteByID(ctx context.Context, id int64) error
+		GetByID(ctx context.Context, id int64, viewerId int64) (*Comment, error)
+		GetDepth(ctx context.Context, id int64) (int, error)
+		List(ctx context.Context, postId int64, viewerId int64, cq PaginationCommentsQuery) ([]Comment, string, error)
+		Update(ctx context.Context, c *Comment) error
 	}
 	Followers interface {
-		Follow(ctx context.Context, userToFollowId int64, followerUserId int64) error
+		ApproveRequest(ctx context.Context, userId int64, requestId int64) error
+		Follow(ctx context.Context, userToFollowId int64, followerUserId int64) (*FollowState, error)
+		ListFollowers(ctx context.Context, userId int64, fq PaginationCursorQuery) ([]FollowListUser, PageCursors, error)
+		ListFollowing(ctx context.Context, userId int64, fq PaginationCursorQuery) ([]FollowListUser, PageCursors, error)
+		ListRequests(ctx context.Context, userId int64, fq PaginationCursorQuery) ([]FollowRequest, PageCursors, error)
+		RejectRequest(ctx context.Context, userId int64, requestId int64) error
 		Unfollow(ctx context.Context, userToUnfollowId int64, followerUserId int64) error
 	}
+	Mutes interface {
+		Mute(ctx context.Context, userId int64, mutedId int64) error
+		Unmute(ctx context.Context, userId int64, mutedId int64) error
+	}
 	Posts interface {
 		Create(ctx context.Context, p *Post) error
 		DeleteByID(ctz context.Context, id int64) error
 		GetByID(ctx context.Context, id int64) (*Post, error)
-		GetUserFeed(ctx context.Context, userId int64, fq PaginationFeedQuery) ([]PostWithMetadata, error)
+		GetExploreFeed(ctx context.Context, viewerId int64, fq PaginationFeedQuery) ([]PostWithMetadata, PageCursors, error)
+		GetMentionsFeed(ctx context.Context, userId int64, fq PaginationFeedQuery) ([]PostWithMetadata, PageCursors, error)
+		GetUserFeed(ctx context.Context, userId int64, fq PaginationFeedQuery) ([]PostWithMetadata, PageCursors, error)
 		Update(ctx context.Context, p *Post) error
 	}
+	Reactions interface {
+		Add(ctx context.Context, postId int64, userId int64, kind string) error
+		GetUserKinds(ctx context.Context, postId int64, userId int64) ([]string, error)
+		Remove(ctx context.Context, postId int64, userId int64, kind string) error
+	}
+	RefreshTokens interface {
+		Create(ctx context.Context, userId int64, token string, exp time.Duration) error
+		Revoke(ctx context.Context, token string) error
+		Rotate(ctx context.Context, token string, newToken string, exp time.Duration) (*RefreshToken, error)
+	}
+	Roles interface {
+		GetByName(ctx context.Context, name string) (*Role, error)
+	}
+	Search interface {
+		Search(ctx context.Context, viewerId int64, sq SearchQuery) ([]SearchResult, PageCursors, error)
+	}
+	Suggestions interface {
+		List(ctx context.Context, userId int64, sq SuggestionsQuery) ([]Suggestion, error)
+		Refresh(ctx context.Context, batchSize int) (int, error)
+	}
+	Tags interface {
+		Follow(ctx context.Context, userId int64, tag string) error
+		GetTrending(ctx context.Context, tq TrendingTagsQuery) ([]TrendingTag, error)
+		RefreshTrending(ctx context.Context) error
+		Unfollow(ctx context.Context, userId int64, tag string) error
+	}
+	Timelines interface {
+		FanOut(ctx context.Context, postId int64, fanoutLimit int) error
+	}
 	Users interface {
 		Activate(ctx context.Context, token string) error
 		Create(ctx context.Context, u *User, tx *sql.Tx) error
 		CreateAndInvite(ctz context.Context, u *User, token string, invitationExp time.Duration) error
+		CreatePasswordReset(ctx context.Context, userId int64, token string, resetExp time.Duration, throttle time.Duration) error
 		Delete(ctx context.Context, userId int64) error
+		DeleteInactive(ctx context.Context, invitedBefore time.Time) (int64, error)
+		GetByEmail(ctx context.Context, email string) (*User, error)
 		GetByID(ctx context.Context, id int64) (*User, error)
+		IsVisibleTo(ctx context.Context, userId int64, viewerId int64) (bool, error)
+		Reinvite(ctx context.Context, userId int64, token string, invitationExp time.Duration, throttle time.Duration) error
+		ResetPassword(ctx context.Context, token string, u *User) error
+		SetPrivate(ctx context.Context, userId int64, private bool) error
 	}
 }
 
-func NewStorage(db *sql.DB) Storage {
+func NewStorage(db *sql.DB, cursorSecret string) Storage {
+	cursors := cursorCodec{secret: []byte(cursorSecret)}
+	followers := &FollowersStore{db, cursors}
+
 	return Storage{
-		Comments:  &CommentStore{db},
-		Followers: &FollowersStore{db},
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
+		Blocks:        &BlockStore{db, followers},
+		Bookmarks:     &BookmarkStore{db, cursors},
+		Comments:      &CommentStore{db, cursors},
+		Followers:     followers,
+		Mutes:         &MuteStore{db},
+		Posts:         &PostStore{db, cursors},
+		Reactions:     &ReactionStore{db},
+		RefreshTokens: &RefreshTokenStore{db},
+		Roles:         &RoleStore{db},
+		Search:        &SearchStore{db, cursors},
+		Suggestions:   &SuggestionStore{db},
+		Tags:          &TagStore{db},
+		Timelines:     &TimelineStore{db},
+		Users:         &UserStore{db},
 	}
 }
 
@@ -60,3 +139,9 @@ func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return tx.Commit()
 }
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
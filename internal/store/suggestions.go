@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// suggestionsPerUser caps how many suggestions are kept for each user.
+const suggestionsPerUser = 50
+
+// suggestionChunkSize is how many users' suggestions are computed together,
+// each chunk in a transaction of its own.
+const suggestionChunkSize = 50
+
+// suggestionWindow is how far back posts count towards shared tags and
+// recent activity.
+const suggestionWindow = "30 days"
+
+// suggestionActiveAuthors caps how many of the most active recent authors are
+// considered for every user, so that users who follow no one and have not
+// posted still get suggestions.
+const suggestionActiveAuthors = 100
+
+// Suggestion is a user someone might want to follow. MutualCount is how many
+// of the users they follow already follow the suggested user, SharedTagCount
+// how many of their recent tags the suggested user has also posted with, and
+// RecentPostCount how active the suggested user has been lately.
+type Suggestion struct {
+	ID              int64   `json:"id"`
+	Username        string  `json:"username"`
+	MutualCount     int     `json:"mutual_count"`
+	SharedTagCount  int     `json:"shared_tag_count"`
+	RecentPostCount int     `json:"recent_post_count"`
+	Score           float32 `json:"score"`
+	ComputedAt      string  `json:"computed_at"`
+}
+
+type SuggestionStore struct {
+	db *sql.DB
+}
+
+// List returns the user's best suggestions as of their last refresh. Users
+// they have followed, asked to follow or blocked since then, and users who
+// are no longer active, are left out.
+func (s *SuggestionStore) List(ctx context.Context, userId int64, sq SuggestionsQuery) ([]Suggestion, error) {
+	query := `
+        SELECT u.id, u.username, fs.mutual_count, fs.shared_tag_count, fs.recent_post_count, fs.score, fs.computed_at
+        FROM follow_suggestions fs
+        JOIN users u ON u.id = fs.suggested_id
+        WHERE fs.user_id = $1 AND u.is_active
+        AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $1)
+        AND NOT EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.user_id = u.id AND fr.requester_id = $1)
+        AND NOT ` + blockedBetween("u.id", "$1") + `
+        ORDER BY fs.score DESC, u.id
+        LIMIT $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, sq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		err := rows.Scan(
+			&suggestion.ID,
+			&suggestion.Username,
+			&suggestion.MutualCount,
+			&suggestion.SharedTagCount,
+			&suggestion.RecentPostCount,
+			&suggestion.Score,
+			&suggestion.ComputedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// Refresh recomputes the suggestions of up to batchSize active users, those
+// never computed first and then those computed longest ago, and returns how
+// many users were refreshed. The users are claimed up front, so a chunk that
+// fails is left until their turn comes round again rather than retried.
+func (s *SuggestionStore) Refresh(ctx context.Context, batchSize int) (int, error) {
+	userIds, err := s.claimBatch(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var refreshed int
+	for chunk := range slices.Chunk(userIds, suggestionChunkSize) {
+		err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+			if err := s.delete(ctx, tx, chunk); err != nil {
+				return err
+			}
+
+			return s.compute(ctx, tx, chunk)
+		})
+		if err != nil {
+			return refreshed, err
+		}
+
+		refreshed += len(chunk)
+	}
+
+	return refreshed, nil
+}
+
+// claimBatch marks the users due a refresh as refreshed and returns them.
+// Users locked by another run or write are skipped, and locks last only as
+// long as the statement.
+func (s *SuggestionStore) claimBatch(ctx context.Context, batchSize int) ([]int64, error) {
+	query := `
+        UPDATE users u
+        SET suggestions_refreshed_at = now()
+        WHERE u.id IN (
+            SELECT du.id
+            FROM users du
+            WHERE du.is_active
+            ORDER BY du.suggestions_refreshed_at NULLS FIRST, du.id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING u.id
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIds := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		userIds = append(userIds, id)
+	}
+
+	return userIds, rows.Err()
+}
+
+func (s *SuggestionStore) delete(ctx context.Context, tx *sql.Tx, userIds []int64) error {
+	query := `DELETE FROM follow_suggestions WHERE user_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(userIds))
+	return err
+}
+
+// compute ranks candidates for each of the users. Candidates are followed by
+// users they follow, have posted with tags they recently used, or are among
+// the most active recent authors. Mutual follows weigh the most, then shared
+// tags, with activity breaking ties. Users they already follow, have asked to
+// follow, or have blocked or been blocked by, are left out.
+func (s *SuggestionStore) compute(ctx context.Context, tx *sql.Tx, userIds []int64) error {
+	query := `
+        WITH batch AS (
+            SELECT unnest($1::bigint[]) AS user_id
+        ), recent AS (
+            SELECT p.user_id, COUNT(*) AS post_count
+            FROM posts p
+            WHERE p.created_at >= now() - $2::interval
+            GROUP BY p.user_id
+        ), mutuals AS (
+            SELECT b.user_id, f2.user_id AS suggested_id, COUNT(*) AS mutual_count
+            FROM batch b
+            JOIN followers f1 ON f1.follower_id = b.user_id
+            JOIN followers f2 ON f2.follower_id = f1.user_id
+            GROUP BY b.user_id, f2.user_id
+        ), own_tags AS (
+            SELECT DISTINCT b.user_id, t.tag
+            FROM batch b
+            JOIN posts p ON p.user_id = b.user_id AND p.created_at >= now() - $2::interval
+            CROSS JOIN LATERAL unnest(p.tags) AS t (tag)
+        ), shared_tags AS (
+            SELECT ot.user_id, p.user_id AS suggested_id, COUNT(DISTINCT ot.tag) AS shared_tag_count
+            FROM own_tags ot
+            JOIN posts p ON p.tags @> ARRAY[ot.tag] AND p.created_at >= now() - $2::interval
+            GROUP BY ot.user_id, p.user_id
+        ), active AS (
+            SELECT b.user_id, a.user_id AS suggested_id
+            FROM batch b
+            CROSS JOIN (
+                SELECT r.user_id FROM recent r ORDER BY r.post_count DESC, r.user_id LIMIT $4
+            ) a
+        ), candidates AS (
+            SELECT user_id, suggested_id, SUM(mutual_count) AS mutual_count, SUM(shared_tag_count) AS shared_tag_count
+            FROM (
+                SELECT user_id, suggested_id, mutual_count, 0 AS shared_tag_count FROM mutuals
+                UNION ALL
+                SELECT user_id, suggested_id, 0, shared_tag_count FROM shared_tags
+                UNION ALL
+                SELECT user_id, suggested_id, 0, 0 FROM active
+            ) c
+            GROUP BY user_id, suggested_id
+        ), scored AS (
+            SELECT
+                c.user_id, c.suggested_id, c.mutual_count, c.shared_tag_count,
+                COALESCE(r.post_count, 0) AS recent_post_count,
+                c.mutual_count * 3 + c.shared_tag_count + ln(1 + COALESCE(r.post_count, 0)) AS score
+            FROM candidates c
+            JOIN users su ON su.id = c.suggested_id AND su.is_active
+            LEFT JOIN recent r ON r.user_id = c.suggested_id
+            WHERE c.suggested_id <> c.user_id
+            AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.user_id = c.suggested_id AND f.follower_id = c.user_id)
+            AND NOT EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.user_id = c.suggested_id AND fr.requester_id = c.user_id)
+            AND NOT ` + blockedBetween("c.suggested_id", "c.user_id") + `
+        ), ranked AS (
+            SELECT *, row_number() OVER (PARTITION BY user_id ORDER BY score DESC, suggested_id) AS position
+            FROM scored
+        )
+        INSERT INTO follow_suggestions (user_id, suggested_id, mutual_count, shared_tag_count, recent_post_count, score)
+        SELECT user_id, suggested_id, mutual_count, shared_tag_count, recent_post_count, score
+        FROM ranked
+        WHERE position <= $3::int
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(userIds), suggestionWindow, suggestionsPerUser, suggestionActiveAuthors)
+	return err
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// trendingBaselinePeriods is how many windows before the current one are
+// averaged into a tag's baseline.
+const trendingBaselinePeriods = 7
+
+// trendingTagsPerWindow caps how many tags are kept for each window.
+const trendingTagsPerWindow = 100
+
+// TrendingTag is a tag that is used more in the window than it usually is.
+// Score is how far PostCount is above Baseline, the average count over the
+// windows before, in a way that lets rare tags rise without letting a
+// single post make a tag trend.
+type TrendingTag struct {
+	Tag        string  `json:"tag"`
+	PostCount  int     `json:"post_count"`
+	Baseline   float32 `json:"baseline"`
+	Score      float32 `json:"score"`
+	ComputedAt string  `json:"computed_at"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// Follow adds the tag to those the user follows. Following it again is a
+// no-op.
+func (s *TagStore) Follow(ctx context.Context, userId int64, tag string) error {
+	query := `
+        INSERT INTO tag_follows (user_id, tag)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, tag)
+	return err
+}
+
+func (s *TagStore) Unfollow(ctx context.Context, userId int64, tag string) error {
+	query := `
+        DELETE FROM tag_follows tf
+        WHERE tf.user_id = $1 AND tf.tag = $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, tag)
+	return err
+}
+
+// GetTrending returns the top trending tags for the query's window, as of the
+// last refresh.
+func (s *TagStore) GetTrending(ctx context.Context, tq TrendingTagsQuery) ([]TrendingTag, error) {
+	query := `
+        SELECT tag, post_count, baseline, score, computed_at
+        FROM trending_tags
+        WHERE time_window = $1
+        ORDER BY score DESC, post_count DESC, tag
+        LIMIT $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tq.Window, tq.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var tag TrendingTag
+		err := rows.Scan(
+			&tag.Tag,
+			&tag.PostCount,
+			&tag.Baseline,
+			&tag.Score,
+			&tag.ComputedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// RefreshTrending recomputes the trending tags for every window. Reposts are
+// left out, so that sharing a post does not count its tags again.
+func (s *TagStore) RefreshTrending(ctx context.Context) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteTrending(ctx, tx); err != nil {
+			return err
+		}
+
+		return s.computeTrending(ctx, tx)
+	})
+}
+
+func (s *TagStore) deleteTrending(ctx context.Context, tx *sql.Tx) error {
+	query := `DELETE FROM trending_tags`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func (s *TagStore) computeTrending(ctx context.Context, tx *sql.Tx) error {
+	query := `
+        WITH windows (name, span) AS (
+            VALUES ('1h', interval '1 hour'), ('24h', interval '24 hours'), ('7d', interval '7 days')
+        ), counts AS (
+            SELECT
+                w.name,
+                t.tag,
+                COUNT(*) FILTER (WHERE p.created_at >= now() - w.span) AS post_count,
+                (COUNT(*) FILTER (WHERE p.created_at < now() - w.span))::real / $1::int AS baseline
+            FROM windows w
+            JOIN posts p ON p.created_at >= now() - w.span * ($1::int + 1)
+            CROSS JOIN LATERAL unnest(p.tags) AS t (tag)
+            WHERE p.repost_of_id IS NULL
+            GROUP BY w.name, t.tag
+        ), scored AS (
+            SELECT
+                name, tag, post_count, baseline,
+                (post_count - baseline) / sqrt(baseline + 1) AS score
+            FROM counts
+            WHERE post_count > 1 AND post_count > baseline
+        ), ranked AS (
+            SELECT *, row_number() OVER (PARTITION BY name ORDER BY score DESC, post_count DESC, tag) AS position
+            FROM scored
+        )
+        INSERT INTO trending_tags (time_window, tag, post_count, baseline, score)
+        SELECT name, tag, post_count, baseline, score
+        FROM ranked
+        WHERE position <= $2::int
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, trendingBaselinePeriods, trendingTagsPerWindow)
+	return err
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// timelineBackfillLimit caps how many of an author's posts are copied into a
+// new follower's timeline.
+const timelineBackfillLimit = 200
+
+type TimelineStore struct {
+	db *sql.DB
+}
+
+// FanOut copies a post into the timelines of its author and their followers.
+// Posts are read on request until then, and stay that way when the author has
+// more than fanoutLimit followers, so that one post never writes more rows
+// than that. The author is locked against new follows while the post is
+// fanned out, since a follow made meanwhile would neither be fanned out to
+// nor backfill a post still read on request.
+func (s *TimelineStore) FanOut(ctx context.Context, postId int64, fanoutLimit int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		authorId, err := s.lockAuthor(ctx, tx, postId)
+		if err != nil {
+			return err
+		}
+
+		followers, err := s.countFollowers(ctx, tx, authorId)
+		if err != nil {
+			return err
+		}
+
+		if followers > fanoutLimit {
+			return nil
+		}
+
+		if err := s.insertForFollowers(ctx, tx, postId, authorId); err != nil {
+			return err
+		}
+
+		return s.markFannedOut(ctx, tx, postId)
+	})
+}
+
+// lockAuthor takes a share lock on the post's author, which conflicts with the
+// lock taken by FollowersStore.lockUsers, and returns them.
+func (s *TimelineStore) lockAuthor(ctx context.Context, tx *sql.Tx, postId int64) (int64, error) {
+	query := `
+        SELECT u.id
+        FROM posts p
+        JOIN users u ON u.id = p.user_id
+        WHERE p.id = $1
+        FOR SHARE OF u
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var authorId int64
+	err := tx.QueryRowContext(ctx, query, postId).Scan(&authorId)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return authorId, nil
+}
+
+func (s *TimelineStore) countFollowers(ctx context.Context, tx *sql.Tx, authorId int64) (int, error) {
+	query := `SELECT COUNT(*) FROM followers f WHERE f.user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var followers int
+	if err := tx.QueryRowContext(ctx, query, authorId).Scan(&followers); err != nil {
+		return 0, err
+	}
+
+	return followers, nil
+}
+
+func (s *TimelineStore) insertForFollowers(ctx context.Context, tx *sql.Tx, postId int64, authorId int64) error {
+	query := `
+        INSERT INTO timelines (user_id, post_id, author_id, created_at)
+        SELECT $2, p.id, p.user_id, p.created_at
+        FROM posts p
+        WHERE p.id = $1
+        UNION
+        SELECT f.follower_id, p.id, p.user_id, p.created_at
+        FROM posts p
+        JOIN followers f ON f.user_id = p.user_id
+        WHERE p.id = $1
+        ON CONFLICT DO NOTHING
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, postId, authorId)
+	return err
+}
+
+func (s *TimelineStore) markFannedOut(ctx context.Context, tx *sql.Tx, postId int64) error {
+	query := `UPDATE posts SET fanout_on_read = false WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, postId)
+	return err
+}
@@ -2,9 +2,7 @@ package store
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"errors"
 	"time"
 
@@ -18,6 +16,13 @@ type User struct {
 	Password  password `json:"-"`
 	CreatedAt string   `json:"created_at"`
 	IsActive  bool     `json:"is_active"`
+	IsPrivate bool     `json:"is_private"`
+	RoleID    int64    `json:"role_id"`
+	Role      Role     `json:"role"`
+	// Kept up to date as users follow each other and post
+	FollowersCount int `json:"followers_count"`
+	FollowingCount int `json:"following_count"`
+	PostsCount     int `json:"posts_count"`
 }
 
 type password struct {
@@ -37,6 +42,10 @@ func (p *password) Set(text string) error {
 	return nil
 }
 
+func (p *password) Compare(text string) error {
+	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+}
+
 type UserStore struct {
 	db *sql.DB
 }
@@ -44,6 +53,7 @@ type UserStore struct {
 var (
 	ErrDuplicateEmail    = errors.New("a user with that email already exists")
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
+	ErrThrottled         = errors.New("too many requests, please try again later")
 )
 
 func (s *UserStore) Activate(ctx context.Context, token string) error {
@@ -116,8 +126,10 @@ func (s *UserStore) Delete(ctx context.Context, userId int64) error {
 
 func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	query := `
-        SELECT id, email, username, created_at 
+        SELECT u.id, u.email, u.username, u.created_at, u.is_active, u.is_private, u.role_id, r.id, r.name, r.level, r.description,
+            u.followers_count, u.following_count, u.posts_count
         FROM users u
+        JOIN roles r ON r.id = u.role_id
         WHERE u.id = $1
     `
 
@@ -131,6 +143,105 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 		&user.Email,
 		&user.Username,
 		&user.CreatedAt,
+		&user.IsActive,
+		&user.IsPrivate,
+		&user.RoleID,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
+		&user.FollowersCount,
+		&user.FollowingCount,
+		&user.PostsCount,
+	); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// IsVisibleTo reports whether the viewer may see the user's posts.
+func (s *UserStore) IsVisibleTo(ctx context.Context, userId int64, viewerId int64) (bool, error) {
+	query := `SELECT ` + visibleTo("u", "$2") + ` FROM users u WHERE u.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	if err := s.db.QueryRowContext(ctx, query, userId, viewerId).Scan(&visible); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return false, ErrNotFound
+		default:
+			return false, err
+		}
+	}
+
+	return visible, nil
+}
+
+// SetPrivate makes the user's posts visible only to their approved followers,
+// or to everyone again.
+func (s *UserStore) SetPrivate(ctx context.Context, userId int64, private bool) error {
+	query := `UPDATE users SET is_private = $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, private)
+	return err
+}
+
+// DeleteInactive removes users that were never activated, have not posted,
+// were last invited before invitedBefore and hold no invitation that is still
+// valid, along with their invitations.
+func (s *UserStore) DeleteInactive(ctx context.Context, invitedBefore time.Time) (int64, error) {
+	var deleted int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		userIds, err := s.deleteInactive(ctx, tx, invitedBefore)
+		if err != nil {
+			return err
+		}
+
+		for _, userId := range userIds {
+			if err := s.deleteUserInvitations(ctx, tx, userId); err != nil {
+				return err
+			}
+		}
+
+		deleted = int64(len(userIds))
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `
+        SELECT id, email, username, password, created_at, is_active
+        FROM users u
+        WHERE u.email = $1
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var user User
+
+	if err := s.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Username,
+		&user.Password.hash,
+		&user.CreatedAt,
+		&user.IsActive,
 	); err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -157,6 +268,72 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, u *User, token string,
 	})
 }
 
+// CreatePasswordReset replaces any unused password resets for an active user
+// with a new one. It returns ErrThrottled if the user was last sent one less
+// than throttle ago.
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userId int64, token string, resetExp time.Duration, throttle time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.markPasswordReset(ctx, tx, userId, throttle); err != nil {
+			return err
+		}
+
+		if err := s.deletePasswordResets(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		return s.createPasswordReset(ctx, tx, userId, token, resetExp)
+	})
+}
+
+// Reinvite replaces every pending invitation for an inactive user with a new
+// one. It returns ErrThrottled if the user was last invited less than
+// throttle ago.
+func (s *UserStore) Reinvite(ctx context.Context, userId int64, token string, invitationExp time.Duration, throttle time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.markInvited(ctx, tx, userId, throttle); err != nil {
+			return err
+		}
+
+		if err := s.deleteUserInvitations(ctx, tx, userId); err != nil {
+			return err
+		}
+
+		if err := s.createUserInvitation(ctx, tx, token, invitationExp, userId); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ResetPassword stores the password set on u for the user the reset token
+// belongs to, then invalidates every outstanding reset and refresh token.
+func (s *UserStore) ResetPassword(ctx context.Context, token string, u *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		user, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		user.Password = u.Password
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		if err := s.revokeRefreshTokens(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		*u = *user
+
+		return nil
+	})
+}
+
 func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, invitationExp time.Duration, userID int64) error {
 	query := `
         INSERT INTO user_invitations (token, user_id, expiry)
@@ -186,6 +363,55 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 	return nil
 }
 
+func (s *UserStore) deleteInactive(ctx context.Context, tx *sql.Tx, invitedBefore time.Time) ([]int64, error) {
+	query := `
+        DELETE FROM users u
+        WHERE u.is_active = false
+        AND u.invited_at < $1
+        AND NOT EXISTS (SELECT 1 FROM user_invitations ui WHERE ui.user_id = u.id AND ui.expiry > now())
+        AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.user_id = u.id)
+        RETURNING u.id
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, invitedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIds := []int64{}
+	for rows.Next() {
+		var userId int64
+		if err := rows.Scan(&userId); err != nil {
+			return nil, err
+		}
+
+		userIds = append(userIds, userId)
+	}
+
+	return userIds, rows.Err()
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userId int64) error {
+	query := `
+        DELETE FROM password_resets pr
+        WHERE pr.user_id = $1
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userId int64) error {
 	query := `
         DELETE FROM user_invitations ui
@@ -203,6 +429,79 @@ func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+func (s *UserStore) createPasswordReset(ctx context.Context, tx *sql.Tx, userId int64, token string, resetExp time.Duration) error {
+	query := `
+        INSERT INTO password_resets (token, user_id, expiry)
+        VALUES ($1, $2, $3)
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, hashToken(token), userId, time.Now().Add(resetExp))
+	return err
+}
+
+// markPasswordReset records that the active user is being sent a password
+// reset now, unless they were last sent one less than throttle ago. Like
+// markInvited, only one of several concurrent requests gets through.
+func (s *UserStore) markPasswordReset(ctx context.Context, tx *sql.Tx, userId int64, throttle time.Duration) error {
+	query := `
+        UPDATE users
+        SET password_reset_at = now()
+        WHERE id = $1 AND is_active AND (password_reset_at IS NULL OR password_reset_at <= $2)
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, userId, time.Now().Add(-throttle))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrThrottled
+	}
+
+	return nil
+}
+
+// markInvited records that the inactive user is being invited now, unless
+// they were last invited less than throttle ago. A concurrent resend waits on
+// the row and then no longer matches, so only one of them gets through.
+func (s *UserStore) markInvited(ctx context.Context, tx *sql.Tx, userId int64, throttle time.Duration) error {
+	query := `
+        UPDATE users
+        SET invited_at = now()
+        WHERE id = $1 AND NOT is_active AND invited_at <= $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, userId, time.Now().Add(-throttle))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrThrottled
+	}
+
+	return nil
+}
+
 func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 	query := `
         SELECT u.id, u.username, u.email, u.created_at, u.is_active
@@ -211,15 +510,43 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
         WHERE ui.token = $1 AND ui.expiry > $2
     `
 
-	hash := sha256.Sum256([]byte(token))
-	hashToken := hex.EncodeToString(hash[:])
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+
+	if err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+        SELECT u.id, u.username, u.email, u.created_at, u.is_active
+        FROM users u
+        JOIN password_resets pr on pr.user_id = u.id
+        WHERE pr.token = $1 AND pr.expiry > $2
+    `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	user := &User{}
 
-	if err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+	if err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -237,6 +564,24 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 	return user, nil
 }
 
+func (s *UserStore) revokeRefreshTokens(ctx context.Context, tx *sql.Tx, userId int64) error {
+	query := `
+        UPDATE refresh_tokens
+        SET revoked_at = now()
+        WHERE user_id = $1 AND revoked_at IS NULL
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
         UPDATE users
@@ -260,3 +605,20 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 
 	return nil
 }
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+        UPDATE users
+        SET password = $1
+        WHERE id = $2
+    `
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, query, user.Password.hash, user.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package store
+
+// visibleTo is a condition that holds when the viewer, a placeholder or
+// column, may see the posts of the user aliased as alias. Private users are
+// only visible to themselves and their approved followers, and users who
+// have blocked each other are hidden from one another.
+func visibleTo(alias string, viewer string) string {
+	return `(NOT ` + blockedBetween(alias+`.id`, viewer) + ` AND (NOT ` + alias + `.is_private OR ` + alias + `.id = ` + viewer + ` OR EXISTS (
+                SELECT 1 FROM followers vis WHERE vis.user_id = ` + alias + `.id AND vis.follower_id = ` + viewer + `
+            )))`
+}
+
+// blockedBetween is a condition that holds when either of the two users has
+// blocked the other.
+func blockedBetween(a string, b string) string {
+	return `EXISTS (
+                SELECT 1 FROM user_blocks blk
+                WHERE (blk.user_id = ` + a + ` AND blk.blocked_id = ` + b + `) OR (blk.user_id = ` + b + ` AND blk.blocked_id = ` + a + `)
+            )`
+}
+
+// mutedBy is a condition that holds when the viewer has muted the user.
+func mutedBy(viewer string, user string) string {
+	return `EXISTS (SELECT 1 FROM user_mutes mut WHERE mut.user_id = ` + viewer + ` AND mut.muted_id = ` + user + `)`
+}